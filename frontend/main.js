@@ -1,4 +1,5 @@
-import { Start, Stop } from './wailsjs/go/core/App.js';
+import { Start, Stop, GetLogs, GetHealth } from './wailsjs/go/core/App.js';
+import { EventsOn } from './wailsjs/runtime/runtime.js';
 
 document.getElementById("startButton").addEventListener("click", function () {
     Start().then(function () {
@@ -6,6 +7,7 @@ document.getElementById("startButton").addEventListener("click", function () {
         document.getElementById("stopButton").disabled = false;
         document.getElementById("startButton").disabled = true;
         document.getElementById("fetchLogsButton").disabled = false;
+        GetHealth().then(displayHealth);
     }).catch(function (err) {
         document.getElementById("status").innerText = "Error: " + err;
     });
@@ -16,24 +18,56 @@ document.getElementById("stopButton").addEventListener("click", function () {
         document.getElementById("status").innerText = "App stopped successfully!";
         document.getElementById("startButton").disabled = false;
         document.getElementById("fetchLogsButton").disabled = true;
+        document.getElementById("nextLogsButton").disabled = true;
         document.getElementById("stopButton").disabled = true;
     }).catch(function (err) {
         document.getElementById("status").innerText = "Error: " + err;
     });
 });
 
+let nextLogsCursor = "";
+
 document.getElementById("fetchLogsButton").addEventListener("click", function () {
-    fetch("http://localhost:9000/v1/logs?limit=2")
-        .then(response => response.json())
+    fetchLogs("");
+});
+
+document.getElementById("nextLogsButton").addEventListener("click", function () {
+    fetchLogs(nextLogsCursor);
+});
+
+function fetchLogs(cursor) {
+    GetLogs({ limit: 2, offset: 0, cursor: cursor, filter: {} })
         .then(data => {
             console.log(data)
-            displayLogsInTable(data);
+            displayLogsInTable(data.items);
+
+            nextLogsCursor = data.next_cursor || "";
+            document.getElementById("nextLogsButton").disabled = !nextLogsCursor;
+            const total = (data.total_estimated ? "about " : "") + data.total;
+            document.getElementById("logsSummary").innerText = data.items.length + " of " + total + " logs";
         })
         .catch(err => {
             document.getElementById("status").innerText = "Error fetching logs: " + err;
         });
+}
+
+EventsOn("file_change", function (log) {
+    document.getElementById("lastChange").innerText = "Last change: " + log.details.action + " " + log.details.target_path;
+});
+
+EventsOn("job", function (job) {
+    document.getElementById("lastJob").innerText = "Last command: " + job.command + " (" + job.status + ")";
 });
 
+EventsOn("health", displayHealth);
+
+function displayHealth(health) {
+    const healthy = health.worker_thread_alive && health.timer_thread_alive;
+    document.getElementById("health").innerText = (healthy ? "Healthy" : "Unhealthy") +
+        ", " + health.command_queue.depth + " commands queued" +
+        ", " + health.reporter.pending + " events pending delivery";
+}
+
 function displayLogsInTable(logs) {
     console.log(logs)
     const logsTableBody = document.getElementById("logsTableBody");
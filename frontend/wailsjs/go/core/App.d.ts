@@ -1,6 +1,14 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
+import {httpserver} from '../models';
+import {commandexecutor} from '../models';
+
+export function GetHealth():Promise<httpserver.HealthCheckResponse>;
+
+export function GetLogs(arg1:httpserver.LogsRequest):Promise<httpserver.LogsResponse>;
 
 export function Start():Promise<void>;
 
 export function Stop():Promise<void>;
+
+export function SubmitCommands(arg1:string,arg2:Array<string>):Promise<Array<commandexecutor.Job>>;
@@ -2,6 +2,14 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function GetHealth() {
+  return window['go']['core']['App']['GetHealth']();
+}
+
+export function GetLogs(arg1) {
+  return window['go']['core']['App']['GetLogs'](arg1);
+}
+
 export function Start() {
   return window['go']['core']['App']['Start']();
 }
@@ -9,3 +17,7 @@ export function Start() {
 export function Stop() {
   return window['go']['core']['App']['Stop']();
 }
+
+export function SubmitCommands(arg1, arg2) {
+  return window['go']['core']['App']['SubmitCommands'](arg1, arg2);
+}
@@ -0,0 +1,331 @@
+export namespace commandexecutor {
+	
+	export class Job {
+	    id: string;
+	    command: string;
+	    status: string;
+	    exit_code: number;
+	    stdout: string;
+	    stderr: string;
+	    error: string;
+	    queued_at?: any;
+	    started_at?: any;
+	    ended_at?: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new Job(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.command = source["command"];
+	        this.status = source["status"];
+	        this.exit_code = source["exit_code"];
+	        this.stdout = source["stdout"];
+	        this.stderr = source["stderr"];
+	        this.error = source["error"];
+	        this.queued_at = this.convertValues(source["queued_at"], null);
+	        this.started_at = this.convertValues(source["started_at"], null);
+	        this.ended_at = this.convertValues(source["ended_at"], null);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class QueueStatus {
+	    depth: number;
+	    capacity: number;
+	    workers: number;
+	    stopped: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new QueueStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.depth = source["depth"];
+	        this.capacity = source["capacity"];
+	        this.workers = source["workers"];
+	        this.stopped = source["stopped"];
+	    }
+	}
+
+}
+
+export namespace httpserver {
+	
+	export class HealthCheckResponse {
+	    worker_thread_alive: boolean;
+	    timer_thread_alive: boolean;
+	    reporter: reporter.DeliveryStatus;
+	    command_queue: commandexecutor.QueueStatus;
+	
+	    static createFrom(source: any = {}) {
+	        return new HealthCheckResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.worker_thread_alive = source["worker_thread_alive"];
+	        this.timer_thread_alive = source["timer_thread_alive"];
+	        this.reporter = this.convertValues(source["reporter"], reporter.DeliveryStatus);
+	        this.command_queue = this.convertValues(source["command_queue"], commandexecutor.QueueStatus);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LogFilterRequest {
+	    path_prefix: string;
+	    path_glob: string;
+	    actions: string[];
+	    since: string;
+	    until: string;
+	    md5: string;
+	    sha256: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogFilterRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path_prefix = source["path_prefix"];
+	        this.path_glob = source["path_glob"];
+	        this.actions = source["actions"];
+	        this.since = source["since"];
+	        this.until = source["until"];
+	        this.md5 = source["md5"];
+	        this.sha256 = source["sha256"];
+	    }
+	}
+	export class LogFilters {
+	    path_prefix: string;
+	    path_glob: string;
+	    actions: string[];
+	    since?: any;
+	    until?: any;
+	    md5: string;
+	    sha256: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogFilters(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path_prefix = source["path_prefix"];
+	        this.path_glob = source["path_glob"];
+	        this.actions = source["actions"];
+	        this.since = this.convertValues(source["since"], null);
+	        this.until = this.convertValues(source["until"], null);
+	        this.md5 = source["md5"];
+	        this.sha256 = source["sha256"];
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LogsRequest {
+	    limit: number;
+	    offset: number;
+	    cursor: string;
+	    filter: LogFilterRequest;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogsRequest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.limit = source["limit"];
+	        this.offset = source["offset"];
+	        this.cursor = source["cursor"];
+	        this.filter = this.convertValues(source["filter"], LogFilterRequest);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class LogsResponse {
+	    items: mongolog.LogEntry[];
+	    total: number;
+	    total_estimated: boolean;
+	    limit: number;
+	    offset: number;
+	    next_offset: number;
+	    next_cursor: string;
+	    filters: LogFilters;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogsResponse(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.items = this.convertValues(source["items"], mongolog.LogEntry);
+	        this.total = source["total"];
+	        this.total_estimated = source["total_estimated"];
+	        this.limit = source["limit"];
+	        this.offset = source["offset"];
+	        this.next_offset = source["next_offset"];
+	        this.next_cursor = source["next_cursor"];
+	        this.filters = this.convertValues(source["filters"], LogFilters);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace mongolog {
+	
+	export class LogEntry {
+	    id: string;
+	    details: {[key: string]: string};
+	    logTime: string;
+	    sha256?: string;
+	    fuzzyHash?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new LogEntry(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.details = source["details"];
+	        this.logTime = source["logTime"];
+	        this.sha256 = source["sha256"];
+	        this.fuzzyHash = source["fuzzyHash"];
+	    }
+	}
+
+}
+
+export namespace reporter {
+	
+	export class DeliveryStatus {
+	    pending: number;
+	    delivered: number;
+	    dropped: number;
+	    consecutive_failures: number;
+	    last_attempt_at?: any;
+	    last_success_at?: any;
+	    next_attempt_at?: any;
+	    last_error: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new DeliveryStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.pending = source["pending"];
+	        this.delivered = source["delivered"];
+	        this.dropped = source["dropped"];
+	        this.consecutive_failures = source["consecutive_failures"];
+	        this.last_attempt_at = this.convertValues(source["last_attempt_at"], null);
+	        this.last_success_at = this.convertValues(source["last_success_at"], null);
+	        this.next_attempt_at = this.convertValues(source["next_attempt_at"], null);
+	        this.last_error = source["last_error"];
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
@@ -2,7 +2,9 @@ package commandexecutor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os/exec"
 	"strings"
@@ -10,6 +12,8 @@ import (
 	"time"
 
 	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/google/uuid"
 )
 
 //go:generate mockgen -destination=../../mocks/commandexecutor/mock_commandexecutor.go -package=commandexecutormock -source=commandexecutor.go
@@ -18,75 +22,89 @@ type CommandExecutor interface {
 	Stop(ctx context.Context) error
 
 	IsWorkerThreadAlive() bool
-	AddCommands(commands []string) error
+	AddCommands(orderingKey string, commands []string) ([]Job, error)
+	GetJob(id string) (Job, error)
+	CancelJob(id string) (Job, error)
+	QueueStatus() QueueStatus
+	CheckPath(path string) error
 }
 
-type commandExecutor struct {
-	commandQueue        chan string
-	appLogger           *slog.Logger
-	config              *config.Config
-	mu                  sync.Mutex
-	workerLastHeartbeat time.Time
+// waitDelay bounds how long a killed command may hold on to its output
+// pipes, e.g. through a child process that escaped the process group
+const waitDelay = 5 * time.Second
+
+var (
+	ErrQueueFull       = errors.New("command queue is full")
+	ErrBatchTooLarge   = errors.New("more commands than the command queue can hold")
+	ErrExecutorStopped = errors.New("command executor is stopped")
+)
+
+// QueueStatus describes how full the command queue is
+type QueueStatus struct {
+	Depth    int  `json:"depth"`
+	Capacity int  `json:"capacity"`
+	Workers  int  `json:"workers"`
+	Stopped  bool `json:"stopped"`
 }
 
-var commandWhitelist = []string{
-	"touch",
-	"mkdir",
+type commandExecutor struct {
+	workers    []*worker
+	queueSize  int // limit of jobs queued across all workers
+	jobs       *jobStore
+	appLogger  *slog.Logger
+	config     *config.Config
+	policies   map[string]config.CommandPolicy
+	watches    WatchLister
+	events     eventbus.Bus
+	queueMu    sync.Mutex // guards sends on the worker queues against them being closed
+	stopped    bool
+	timeout    time.Duration
+	runCtx     context.Context // parent of every command, canceled by Stop
+	cancelRuns context.CancelFunc
 }
 
-func New(appLogger *slog.Logger, cfg *config.Config) CommandExecutor {
-	return &commandExecutor{
-		commandQueue: make(chan string, 100),
-		appLogger:    appLogger,
-		config:       cfg,
+// New creates a command executor. watches provides the tracked directories
+// path arguments are checked against, cfg.Directories is used when nil.
+// Every job status change is published to events.
+func New(appLogger *slog.Logger, cfg *config.Config, watches WatchLister, events eventbus.Bus) CommandExecutor {
+	policies := make(map[string]config.CommandPolicy, len(cfg.Commands))
+	for _, p := range cfg.Commands {
+		policies[p.Name] = p
 	}
-}
 
-func (f *commandExecutor) drainCommandQueue() {
-	for newCmd := range f.commandQueue {
-		err := f.executeCommand(newCmd)
-		if err != nil {
-			f.appLogger.Error("error-executing-command", slog.String("error", err.Error()))
-		}
+	queueSize := cfg.CommandQueueSize
+	if queueSize < 1 {
+		queueSize = config.DefaultCommandQueueSize
+	}
+	timeout := cfg.CommandTimeout
+	if timeout < 1 {
+		timeout = config.DefaultCommandTimeout
+	}
+	workerCount := cfg.CommandWorkers
+	if workerCount < 1 {
+		workerCount = config.DefaultCommandWorkers
 	}
-}
 
-func (f *commandExecutor) workerThread(ctx context.Context) {
-	ticker := time.NewTicker(10 * time.Second) // Heartbeat every 10 seconds
-	defer func() {
-		ticker.Stop()
-		if f.commandQueue != nil {
-			f.drainCommandQueue() // process all queued commands before shutdown
-			close(f.commandQueue)
-			f.commandQueue = nil
-		}
-	}()
-
-	for {
-		select {
-		case <-ctx.Done():
-			f.appLogger.Info("command-executor-shutdown")
-			return
-		case <-ticker.C:
-			f.mu.Lock()
-			f.workerLastHeartbeat = time.Now()
-			f.mu.Unlock()
-		case newCmd := <-f.commandQueue:
-			err := f.executeCommand(newCmd)
-			if err != nil {
-				f.appLogger.Error("error-executing-command", slog.String("error", err.Error()))
-			}
-		}
+	// every worker queue can hold the whole limit so sends never block
+	workers := make([]*worker, workerCount)
+	for i := range workers {
+		workers[i] = newWorker(i, queueSize)
 	}
-}
+	runCtx, cancelRuns := context.WithCancel(context.Background())
 
-func isCommandWhitelisted(command string) bool {
-	for _, allowedCmd := range commandWhitelist {
-		if command == allowedCmd {
-			return true
-		}
+	return &commandExecutor{
+		workers:    workers,
+		queueSize:  queueSize,
+		timeout:    time.Duration(timeout) * time.Second,
+		runCtx:     runCtx,
+		cancelRuns: cancelRuns,
+		jobs:       newJobStore(),
+		appLogger:  appLogger,
+		config:     cfg,
+		policies:   policies,
+		watches:    watches,
+		events:     events,
 	}
-	return false
 }
 
 func parseCommand(input string) (command string, args []string, err error) {
@@ -108,46 +126,247 @@ func parseCommand(input string) (command string, args []string, err error) {
 	return command, args, nil
 }
 
-func (f *commandExecutor) executeCommand(input string) error {
-	command, args, err := parseCommand(input)
+// runJob executes a queued job and records its result
+func (f *commandExecutor) runJob(id string) {
+	if f.runCtx.Err() != nil {
+		// stopped, the rest of the queue is not run
+		job, err := f.jobs.cancel(id)
+		if err == nil {
+			f.publishJob(job)
+		}
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(f.runCtx, f.timeout)
+	defer cancel()
+
+	job, ok, err := f.jobs.start(id, cancel)
 	if err != nil {
-		return err
+		f.appLogger.Error("error-starting-job", slog.String("job_id", id), slog.String("error", err.Error()))
+		return
+	}
+	if !ok {
+		return // canceled while queued
 	}
+	f.publishJob(job)
 
-	if !isCommandWhitelisted(command) {
-		return fmt.Errorf("execution blocked: command: %s is not whitelisted", command)
+	var stdout, stderr limitedBuffer
+	killed, execErr := f.executeCommand(ctx, job.Command, &stdout, &stderr)
+	if execErr != nil {
+		f.appLogger.Error("error-executing-command", slog.String("job_id", id), slog.String("error", execErr.Error()))
 	}
 
-	cmd := exec.Command(command, args...)
-	err = cmd.Run()
+	job, err = f.jobs.finish(id, func(job *Job) {
+		job.Stdout = stdout.String()
+		job.Stderr = stderr.String()
+		job.ExitCode = exitCode(execErr)
+		job.Status = JobStatusSucceeded
+		// a timeout or cancel only counts when it killed the command, one
+		// that came in as the command exited leaves its result as it was
+		switch {
+		case killed && errors.Is(ctx.Err(), context.DeadlineExceeded):
+			job.Status = JobStatusTimedOut
+			job.Error = fmt.Sprintf("command timed out after %s", f.timeout)
+		case killed && errors.Is(ctx.Err(), context.Canceled):
+			job.Status = JobStatusCanceled
+			job.Error = "command was canceled"
+		case execErr != nil:
+			job.Status = JobStatusFailed
+			job.Error = execErr.Error()
+		}
+	})
 	if err != nil {
-		return fmt.Errorf("error executing command: %w", err)
+		f.appLogger.Error("error-finishing-job", slog.String("job_id", id), slog.String("error", err.Error()))
+		return
+	}
+	f.publishJob(job)
+}
+
+// exitCode returns the exit code of a finished command, nil when the
+// command never ran
+func exitCode(err error) *int {
+	code := 0
+	if err == nil {
+		return &code
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+		return &code
 	}
 
 	return nil
 }
 
+// executeCommand runs input until it exits or ctx is done, killed reports
+// whether ctx being done is what ended it or kept it from starting
+func (f *commandExecutor) executeCommand(ctx context.Context, input string, stdout, stderr io.Writer) (killed bool, err error) {
+	// checked again as the tracked directories may have changed since the
+	// command was queued
+	command, args, err := f.checkCommand(input)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	killProcessGroupOnCancel(cmd)
+	interrupted := false
+	kill := cmd.Cancel
+	cmd.Cancel = func() error {
+		err := kill()
+		interrupted = err == nil
+		return err
+	}
+
+	err = cmd.Run()
+	if cmd.ProcessState != nil && cmd.ProcessState.Success() {
+		// the command exited on its own before the kill reached it, Run
+		// still reports the context's error
+		if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+			err = nil
+		}
+		interrupted = false
+	}
+	if cmd.ProcessState == nil && ctx.Err() != nil {
+		// ctx was done before the command could start
+		interrupted = true
+	}
+	if err != nil {
+		return interrupted, fmt.Errorf("error executing command: %w", err)
+	}
+
+	return false, nil
+}
+
 func (f *commandExecutor) Start(ctx context.Context) error {
-	go f.workerThread(ctx)
+	for _, w := range f.workers {
+		go f.workerThread(ctx, w)
+	}
 
 	return nil
 }
 
+// Stop rejects new commands and interrupts the running one, commands still
+// queued are marked as canceled when the queue is drained
 func (f *commandExecutor) Stop(ctx context.Context) error {
+	f.queueMu.Lock()
+	defer f.queueMu.Unlock()
+
+	f.stopped = true
+	f.cancelRuns()
 	return nil
 }
 
+// closeQueue stops accepting commands and closes the queue of w so it can be drained
+func (f *commandExecutor) closeQueue(w *worker) {
+	f.queueMu.Lock()
+	defer f.queueMu.Unlock()
+
+	f.stopped = true
+	close(w.queue)
+}
+
+// IsWorkerThreadAlive reports whether every worker of the pool is alive
 func (f *commandExecutor) IsWorkerThreadAlive() bool {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	for _, w := range f.workers {
+		if !w.isAlive() {
+			return false
+		}
+	}
 
-	return time.Since(f.workerLastHeartbeat) < 2*time.Minute
+	return true
 }
 
-func (f *commandExecutor) AddCommands(commands []string) error {
+// queueDepth is the number of jobs waiting across all workers, the caller
+// must hold queueMu
+func (f *commandExecutor) queueDepth() int {
+	depth := 0
+	for _, w := range f.workers {
+		depth += len(w.queue)
+	}
+	return depth
+}
+
+// AddCommands queues commands for execution without blocking. Every command
+// is checked against the policies first, and nothing is queued if one is
+// rejected or the queue lacks room for all of them.
+// A batch larger than the queue's capacity returns ErrBatchTooLarge.
+//
+// Commands sharing an ordering key run one after another in submission
+// order, commands with different keys may run in parallel. An empty key
+// keeps the commands of this call in order.
+func (f *commandExecutor) AddCommands(orderingKey string, commands []string) ([]Job, error) {
 	for _, cmd := range commands {
-		f.commandQueue <- cmd
+		_, _, err := f.checkCommand(cmd)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCommandRejected, cmd, err)
+		}
 	}
 
-	return nil
+	f.queueMu.Lock()
+	defer f.queueMu.Unlock()
+
+	if f.stopped {
+		return nil, ErrExecutorStopped
+	}
+	// a batch larger than the whole queue never fits, however long it waits
+	if len(commands) > f.queueSize {
+		return nil, fmt.Errorf("%w: %d commands, command_queue_size is %d", ErrBatchTooLarge, len(commands), f.queueSize)
+	}
+	if f.queueSize-f.queueDepth() < len(commands) {
+		return nil, ErrQueueFull
+	}
+
+	if orderingKey == "" {
+		orderingKey = uuid.NewString()
+	}
+	w := f.workerFor(orderingKey)
+
+	// sends can't block: only AddCommands sends and the room was checked
+	// while holding queueMu
+	jobs := make([]Job, 0, len(commands))
+	for _, cmd := range commands {
+		job := f.jobs.create(cmd)
+		w.queue <- job.ID
+		jobs = append(jobs, job)
+		f.publishJob(job)
+	}
+
+	return jobs, nil
+}
+
+func (f *commandExecutor) QueueStatus() QueueStatus {
+	f.queueMu.Lock()
+	defer f.queueMu.Unlock()
+
+	return QueueStatus{
+		Depth:    f.queueDepth(),
+		Capacity: f.queueSize,
+		Workers:  len(f.workers),
+		Stopped:  f.stopped,
+	}
+}
+
+func (f *commandExecutor) GetJob(id string) (Job, error) {
+	return f.jobs.get(id)
+}
+
+func (f *commandExecutor) CancelJob(id string) (Job, error) {
+	job, err := f.jobs.cancel(id)
+	if err != nil {
+		return job, err
+	}
+	if job.Done() {
+		f.publishJob(job) // a running job is published by runJob once it exited
+	}
+
+	return job, nil
+}
+
+func (f *commandExecutor) publishJob(job Job) {
+	f.events.Publish(eventbus.Event{Topic: eventbus.TopicJob, ID: job.ID, Data: job})
 }
@@ -1,33 +1,55 @@
 package commandexecutor
 
 import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strconv"
 	"testing"
 	"time"
 
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
 // go test -v -cover ./internal/commandexecutor/...
 
-// go test -v -cover -run TestIsCommandWhitelisted ./internal/commandexecutor
-func TestIsCommandWhitelisted(t *testing.T) {
+// newTestExecutor returns an executor with the default policies that
+// tracks a fresh temp directory
+func newTestExecutor(t *testing.T) (*commandExecutor, string) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: dir, Recursive: true}},
+		Commands:    config.DefaultCommandPolicies(),
+	}
+
+	return New(slog.Default(), cfg, nil, eventbus.New(slog.Default())).(*commandExecutor), dir
+}
+
+// go test -v -cover -run TestCheckCommand_Whitelist ./internal/commandexecutor
+func TestCheckCommand_Whitelist(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	file := filepath.Join(dir, "test.txt")
+
 	tests := []struct {
 		name     string
 		command  string
 		expected bool
 	}{
-		{"Command is whitelisted - touch", "touch", true},
-		{"Command is whitelisted - mkdir", "mkdir", true},
-		{"Command is not whitelisted - rm", "rm", false},
-		{"Command is not whitelisted - sudo rm", "sudo rm", false},
+		{"Command is whitelisted - touch", "touch " + file, true},
+		{"Command is whitelisted - mkdir", "mkdir " + file, true},
+		{"Command is not whitelisted - rm", "rm " + file, false},
+		{"Command is not whitelisted - sudo rm", "sudo rm " + file, false},
 		{"Empty command", "", false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := isCommandWhitelisted(tt.command)
-			assert.Equal(t, result, tt.expected)
+			_, _, err := executor.checkCommand(tt.command)
+			assert.Equal(t, err == nil, tt.expected)
 		})
 	}
 }
@@ -79,34 +101,356 @@ func TestParseCommand_Errors(t *testing.T) {
 
 // go test -v -cover -run TestAddCommands ./internal/commandexecutor
 func TestAddCommands(t *testing.T) {
-	executor := &commandExecutor{
-		commandQueue: make(chan string, 3),
-	}
+	executor, dir := newTestExecutor(t)
+	queue := executor.workerFor("test").queue
 
 	tests := []struct {
 		name     string
 		commands []string
 	}{
-		{"Single command", []string{"ls"}},
-		{"Multiple commands", []string{"ls", "echo", "cat /etc/passwd"}},
+		{"Single command", []string{"touch " + filepath.Join(dir, "a.txt")}},
+		{"Multiple commands", []string{"mkdir -p " + filepath.Join(dir, "b"), "touch " + filepath.Join(dir, "b", "c.txt"), "sudo touch " + filepath.Join(dir, "d.txt")}},
 		{"No commands", []string{}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := executor.AddCommands(tt.commands)
+			jobs, err := executor.AddCommands("test", tt.commands)
 			require.NoError(t, err)
+			require.Len(t, jobs, len(tt.commands))
+
+			for i, expectedCmd := range tt.commands {
+				assert.Equal(t, JobStatusQueued, jobs[i].Status)
 
-			for _, expectedCmd := range tt.commands {
 				select {
-				case cmd := <-executor.commandQueue:
-					assert.Equal(t, expectedCmd, cmd)
+				case jobID := <-queue:
+					assert.Equal(t, jobs[i].ID, jobID)
+
+					job, err := executor.GetJob(jobID)
+					require.NoError(t, err)
+					assert.Equal(t, expectedCmd, job.Command)
 				case <-time.After(1 * time.Second):
 					t.Errorf("expected command %q was not added to the queue in time", expectedCmd)
 				}
 			}
 
-			assert.Len(t, executor.commandQueue, 0)
+			assert.Len(t, queue, 0)
 		})
 	}
 }
+
+// go test -v -cover -run TestAddCommands_Rejected ./internal/commandexecutor
+func TestAddCommands_Rejected(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+
+	jobs, err := executor.AddCommands("", []string{
+		"touch " + filepath.Join(dir, "a.txt"),
+		"touch /etc/anything",
+	})
+	assert.ErrorIs(t, err, ErrCommandRejected)
+	assert.ErrorContains(t, err, "outside the tracked directories")
+	assert.Empty(t, jobs)
+	assert.Equal(t, 0, executor.QueueStatus().Depth) // nothing is queued when one command is rejected
+}
+
+// go test -v -cover -run TestAddCommands_QueueFull ./internal/commandexecutor
+func TestAddCommands_QueueFull(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	executor.queueSize = 2
+
+	touch := "touch " + filepath.Join(dir, "a.txt")
+
+	_, err := executor.AddCommands("", []string{touch, touch, touch})
+	assert.ErrorIs(t, err, ErrBatchTooLarge) // could never fit
+
+	_, err = executor.AddCommands("first", []string{touch})
+	require.NoError(t, err)
+
+	_, err = executor.AddCommands("", []string{touch, touch})
+	assert.ErrorIs(t, err, ErrQueueFull)
+	assert.Equal(t, 1, executor.QueueStatus().Depth) // all or nothing
+
+	_, err = executor.AddCommands("second", []string{touch})
+	require.NoError(t, err)
+
+	_, err = executor.AddCommands("third", []string{touch}) // the limit spans all workers
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	status := executor.QueueStatus()
+	assert.Equal(t, 2, status.Depth)
+	assert.Equal(t, 2, status.Capacity)
+}
+
+// go test -v -cover -run TestAddCommands_Stopped ./internal/commandexecutor
+func TestAddCommands_Stopped(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+
+	require.NoError(t, executor.Stop(context.Background()))
+
+	_, err := executor.AddCommands("", []string{"touch " + filepath.Join(dir, "a.txt")})
+	assert.ErrorIs(t, err, ErrExecutorStopped)
+	assert.True(t, executor.QueueStatus().Stopped)
+}
+
+// go test -v -cover -run TestWorkerThread_DrainsOnShutdown ./internal/commandexecutor
+func TestWorkerThread_DrainsOnShutdown(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+
+	jobs, err := executor.AddCommands("test", []string{
+		"touch " + filepath.Join(dir, "a.txt"),
+		"touch " + filepath.Join(dir, "b.txt"),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	executor.workerThread(ctx, executor.workerFor("test")) // returns once the queue is drained
+
+	for _, job := range jobs {
+		res, err := executor.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobStatusSucceeded, res.Status)
+	}
+
+	_, err = executor.AddCommands("", []string{"touch " + filepath.Join(dir, "c.txt")})
+	assert.ErrorIs(t, err, ErrExecutorStopped)
+}
+
+// go test -v -cover -run TestWorkerPool_Ordering ./internal/commandexecutor
+func TestWorkerPool_Ordering(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	executor.policies["sleep"] = config.CommandPolicy{Name: "sleep", AnyPath: true}
+	require.Len(t, executor.workers, config.DefaultCommandWorkers)
+
+	// commands of one key land on one worker in submission order
+	first, err := executor.AddCommands("ordered", []string{"mkdir " + filepath.Join(dir, "a")})
+	require.NoError(t, err)
+	second, err := executor.AddCommands("ordered", []string{"mkdir " + filepath.Join(dir, "a", "b")})
+	require.NoError(t, err)
+
+	queue := executor.workerFor("ordered").queue
+	require.Len(t, queue, 2)
+	assert.Equal(t, first[0].ID, <-queue)
+	assert.Equal(t, second[0].ID, <-queue)
+
+	// separate keys run in parallel, one key per worker sleeps at once
+	keys := make(map[*worker]string)
+	for i := 0; len(keys) < len(executor.workers); i++ {
+		key := strconv.Itoa(i)
+		if _, ok := keys[executor.workerFor(key)]; !ok {
+			keys[executor.workerFor(key)] = key
+		}
+	}
+	var jobs []Job
+	for _, key := range keys {
+		res, err := executor.AddCommands(key, []string{"sleep 1"})
+		require.NoError(t, err)
+		jobs = append(jobs, res...)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, executor.Start(ctx))
+
+	start := time.Now()
+	require.Eventually(t, func() bool {
+		for _, job := range jobs {
+			res, _ := executor.GetJob(job.ID)
+			if !res.Done() {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Less(t, time.Since(start), time.Duration(len(jobs))*time.Second) // not one after another
+}
+
+// go test -v -cover -run TestIsWorkerThreadAlive ./internal/commandexecutor
+func TestIsWorkerThreadAlive(t *testing.T) {
+	executor, _ := newTestExecutor(t)
+
+	assert.False(t, executor.IsWorkerThreadAlive())
+
+	for _, w := range executor.workers {
+		w.heartbeat()
+	}
+	assert.True(t, executor.IsWorkerThreadAlive())
+
+	executor.workers[1].lastHeartbeat = time.Now().Add(-3 * time.Minute)
+	assert.False(t, executor.IsWorkerThreadAlive()) // one stuck worker fails the pool
+}
+
+// go test -v -cover -run TestRunJob ./internal/commandexecutor
+func TestRunJob(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+
+	tests := []struct {
+		name             string
+		command          string
+		expectedStatus   JobStatus
+		expectedExitCode *int
+		expectedStderr   bool
+	}{
+		{"Command succeeds", "mkdir " + filepath.Join(dir, "new"), JobStatusSucceeded, intPtr(0), false},
+		{"Command exits non-zero", "mkdir " + filepath.Join(dir, "missing", "nested"), JobStatusFailed, intPtr(1), true},
+		{"Command not whitelisted", "rm -rf " + dir, JobStatusFailed, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := executor.jobs.create(tt.command)
+			executor.runJob(job.ID)
+
+			res, err := executor.GetJob(job.ID)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, res.Status)
+			assert.Equal(t, tt.expectedExitCode, res.ExitCode)
+			assert.Equal(t, tt.expectedStderr, res.Stderr != "")
+			assert.NotNil(t, res.StartedAt)
+			assert.NotNil(t, res.EndedAt)
+			if tt.expectedStatus == JobStatusFailed {
+				assert.NotEmpty(t, res.Error)
+			}
+		})
+	}
+}
+
+// go test -v -cover -run TestRunJob_Timeout ./internal/commandexecutor
+func TestRunJob_Timeout(t *testing.T) {
+	executor, _ := newTestExecutor(t)
+	executor.policies["sleep"] = config.CommandPolicy{Name: "sleep", AnyPath: true}
+	executor.timeout = 100 * time.Millisecond
+
+	job := executor.jobs.create("sleep 10")
+	start := time.Now()
+	executor.runJob(job.ID)
+
+	res, err := executor.GetJob(job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, JobStatusTimedOut, res.Status)
+	assert.Contains(t, res.Error, "timed out")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+// go test -v -cover -run TestCancelJob ./internal/commandexecutor
+func TestCancelJob(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	executor.policies["sleep"] = config.CommandPolicy{Name: "sleep", AnyPath: true}
+
+	t.Run("Queued job", func(t *testing.T) {
+		jobs, err := executor.AddCommands("test", []string{"touch " + filepath.Join(dir, "a.txt")})
+		require.NoError(t, err)
+
+		job, err := executor.CancelJob(jobs[0].ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobStatusCanceled, job.Status)
+
+		executor.runJob(<-executor.workerFor("test").queue) // skipped
+		res, err := executor.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobStatusCanceled, res.Status)
+		assert.Nil(t, res.StartedAt)
+		assert.NoFileExists(t, filepath.Join(dir, "a.txt"))
+	})
+
+	t.Run("Running job", func(t *testing.T) {
+		job := executor.jobs.create("sleep 10")
+		done := make(chan struct{})
+		go func() {
+			executor.runJob(job.ID)
+			close(done)
+		}()
+
+		require.Eventually(t, func() bool {
+			res, _ := executor.GetJob(job.ID)
+			return res.Status == JobStatusRunning
+		}, time.Second, 10*time.Millisecond)
+
+		_, err := executor.CancelJob(job.ID)
+		require.NoError(t, err)
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("running job was not interrupted")
+		}
+
+		res, err := executor.GetJob(job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, JobStatusCanceled, res.Status)
+
+		_, err = executor.CancelJob(job.ID)
+		assert.ErrorIs(t, err, ErrJobFinished)
+	})
+
+	t.Run("Unknown job", func(t *testing.T) {
+		_, err := executor.CancelJob("unknown")
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+}
+
+// go test -v -cover -run TestExecuteCommand_Killed ./internal/commandexecutor
+func TestExecuteCommand_Killed(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	executor.policies["sleep"] = config.CommandPolicy{Name: "sleep", AnyPath: true}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	killed, err := executor.executeCommand(ctx, "sleep 10", io.Discard, io.Discard)
+	assert.Error(t, err)
+	assert.True(t, killed)
+
+	// done before it could start
+	killed, err = executor.executeCommand(ctx, "touch "+filepath.Join(dir, "a.txt"), io.Discard, io.Discard)
+	assert.Error(t, err)
+	assert.True(t, killed)
+
+	killed, err = executor.executeCommand(context.Background(), "touch "+filepath.Join(dir, "a.txt"), io.Discard, io.Discard)
+	assert.NoError(t, err)
+	assert.False(t, killed)
+}
+
+// go test -v -cover -run TestRunJob_PublishesUpdates ./internal/commandexecutor
+func TestRunJob_PublishesUpdates(t *testing.T) {
+	executor, dir := newTestExecutor(t)
+	sub := executor.events.Subscribe(nil)
+
+	jobs, err := executor.AddCommands("test", []string{"touch " + filepath.Join(dir, "a.txt")})
+	require.NoError(t, err)
+	executor.runJob(<-executor.workerFor("test").queue)
+
+	var statuses []JobStatus
+	for len(sub.Events()) > 0 {
+		event := <-sub.Events()
+		assert.Equal(t, eventbus.TopicJob, event.Topic)
+		assert.Equal(t, jobs[0].ID, event.ID)
+		statuses = append(statuses, event.Data.(Job).Status)
+	}
+	assert.Equal(t, []JobStatus{JobStatusQueued, JobStatusRunning, JobStatusSucceeded}, statuses)
+}
+
+// go test -v -cover -run TestGetJob_NotFound ./internal/commandexecutor
+func TestGetJob_NotFound(t *testing.T) {
+	executor, _ := newTestExecutor(t)
+
+	_, err := executor.GetJob("unknown")
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+// go test -v -cover -run TestLimitedBuffer ./internal/commandexecutor
+func TestLimitedBuffer(t *testing.T) {
+	var buf limitedBuffer
+
+	n, err := buf.Write(make([]byte, maxOutputSize+10))
+	assert.NoError(t, err)
+	assert.Equal(t, maxOutputSize+10, n)
+	assert.True(t, buf.truncated)
+	assert.Contains(t, buf.String(), "[output truncated]")
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,214 @@
+package commandexecutor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// maxJobs is how many jobs are kept for polling, the oldest finished jobs
+	// are forgotten first
+	maxJobs = 1000
+	// maxOutputSize caps the captured stdout and stderr of a job
+	maxOutputSize = 64 * 1024
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusTimedOut  JobStatus = "timed_out"
+	JobStatusCanceled  JobStatus = "canceled"
+)
+
+// Job is a submitted command and the result of running it
+type Job struct {
+	ID        string     `json:"id"`
+	Command   string     `json:"command"`
+	Status    JobStatus  `json:"status"`
+	ExitCode  *int       `json:"exit_code,omitempty"`
+	Stdout    string     `json:"stdout"`
+	Stderr    string     `json:"stderr"`
+	Error     string     `json:"error,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// Done reports whether the job reached a final status
+func (j Job) Done() bool {
+	return j.Status != JobStatusQueued && j.Status != JobStatusRunning
+}
+
+// jobStore keeps jobs in submission order so the oldest can be evicted
+type jobStore struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	order   []string
+	cancels map[string]context.CancelFunc // of running jobs
+}
+
+func newJobStore() *jobStore {
+	return &jobStore{
+		jobs:    make(map[string]*Job),
+		cancels: make(map[string]context.CancelFunc),
+	}
+}
+
+func (s *jobStore) create(command string) Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job := &Job{
+		ID:       uuid.NewString(),
+		Command:  command,
+		Status:   JobStatusQueued,
+		QueuedAt: time.Now(),
+	}
+	s.jobs[job.ID] = job
+	s.order = append(s.order, job.ID)
+	s.evict()
+
+	return *job
+}
+
+// evict forgets the oldest finished jobs once there are more than maxJobs
+func (s *jobStore) evict() {
+	excess := len(s.order) - maxJobs
+	if excess <= 0 {
+		return
+	}
+
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if excess > 0 && s.jobs[id].Done() {
+			delete(s.jobs, id)
+			excess--
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+func (s *jobStore) get(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	return *job, nil
+}
+
+// start marks a queued job as running and keeps cancel to interrupt it,
+// ok is false when the job was canceled while it was queued
+func (s *jobStore) start(id string, cancel context.CancelFunc) (job Job, ok bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, found := s.jobs[id]
+	if !found {
+		return Job{}, false, ErrJobNotFound
+	}
+	if j.Status != JobStatusQueued {
+		return *j, false, nil
+	}
+
+	now := time.Now()
+	j.Status = JobStatusRunning
+	j.StartedAt = &now
+	s.cancels[id] = cancel
+
+	return *j, true, nil
+}
+
+// finish applies the result of a job that was running
+func (s *jobStore) finish(id string, fn func(job *Job)) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.cancels, id)
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+	now := time.Now()
+	job.EndedAt = &now
+	fn(job)
+
+	return *job, nil
+}
+
+// cancel marks a queued job as canceled or interrupts a running one, the
+// running job records its canceled status once its process exited
+func (s *jobStore) cancel(id string) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[id]
+	if !ok {
+		return Job{}, ErrJobNotFound
+	}
+
+	switch job.Status {
+	case JobStatusQueued:
+		now := time.Now()
+		job.Status = JobStatusCanceled
+		job.EndedAt = &now
+		job.Error = "canceled before it started"
+	case JobStatusRunning:
+		if cancel := s.cancels[id]; cancel != nil {
+			cancel()
+		}
+	default:
+		return *job, ErrJobFinished
+	}
+
+	return *job, nil
+}
+
+// limitedBuffer captures command output up to maxOutputSize bytes and
+// silently discards the rest so a chatty command can't exhaust memory
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := maxOutputSize - b.buf.Len()
+	if remaining <= 0 {
+		b.truncated = true
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n[output truncated]"
+	}
+	return b.buf.String()
+}
@@ -0,0 +1,152 @@
+package commandexecutor
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+)
+
+var ErrCommandRejected = errors.New("command rejected")
+
+// WatchLister provides the tracked directories path arguments must stay within
+type WatchLister interface {
+	ListWatches() []config.WatchConfig
+}
+
+// checkCommand parses input and checks it against the configured policies
+func (f *commandExecutor) checkCommand(input string) (command string, args []string, err error) {
+	command, args, err = parseCommand(input)
+	if err != nil {
+		return command, args, err
+	}
+
+	policy, ok := f.policies[command]
+	if !ok {
+		return command, args, fmt.Errorf("execution blocked: command: %s is not whitelisted", command)
+	}
+
+	if policy.MaxArgs > 0 && len(args) > policy.MaxArgs {
+		return command, args, fmt.Errorf("execution blocked: command: %s accepts at most %d arguments", command, policy.MaxArgs)
+	}
+
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			if !contains(policy.AllowedFlags, arg) {
+				return command, args, fmt.Errorf("execution blocked: flag: %s is not allowed for command: %s", arg, command)
+			}
+			continue
+		}
+
+		if policy.AnyPath {
+			continue
+		}
+
+		err = f.checkPath(arg)
+		if err != nil {
+			return command, args, fmt.Errorf("execution blocked: %w", err)
+		}
+	}
+
+	return command, args, nil
+}
+
+// CheckPath applies the policy on command path arguments to a path written
+// outside of a command, e.g. a restored file
+func (f *commandExecutor) CheckPath(path string) error {
+	err := f.checkPath(path)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCommandRejected, err)
+	}
+
+	return nil
+}
+
+// checkPath ensures path resolves, after following symlinks, to a location
+// inside one of the tracked directories.
+func (f *commandExecutor) checkPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("path: %s must be absolute", path)
+	}
+	for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+		if elem == ".." {
+			return fmt.Errorf("path: %s must not contain '..'", path)
+		}
+	}
+
+	resolved, err := resolvePath(path)
+	if err != nil {
+		return fmt.Errorf("error resolving path: %s: %w", path, err)
+	}
+
+	for _, root := range f.trackedDirectories() {
+		resolvedRoot, err := resolvePath(root)
+		if err != nil {
+			continue
+		}
+		if isWithin(resolved, resolvedRoot) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("path: %s is outside the tracked directories", path)
+}
+
+func (f *commandExecutor) trackedDirectories() []string {
+	watches := f.config.Directories
+	if f.watches != nil {
+		watches = f.watches.ListWatches()
+	}
+
+	dirs := make([]string, 0, len(watches))
+	for _, w := range watches {
+		dirs = append(dirs, w.Path)
+	}
+	return dirs
+}
+
+// resolvePath follows symlinks in the longest existing part of path, the
+// part that does not exist yet (e.g. a file about to be created) is
+// appended as is.
+func resolvePath(path string) (string, error) {
+	existing := filepath.Clean(path)
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return filepath.Clean(path), nil
+		}
+		missing = append([]string{filepath.Base(existing)}, missing...)
+		existing = parent
+	}
+}
+
+// isWithin reports whether path is strictly inside root
+func isWithin(path, root string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
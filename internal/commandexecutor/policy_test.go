@@ -0,0 +1,80 @@
+package commandexecutor
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticWatches []config.WatchConfig
+
+func (s staticWatches) ListWatches() []config.WatchConfig {
+	return s
+}
+
+// go test -v -cover -run TestCheckCommand_Policies ./internal/commandexecutor
+func TestCheckCommand_Policies(t *testing.T) {
+	tracked := t.TempDir()
+	outside := t.TempDir()
+	require.NoError(t, os.Symlink(outside, filepath.Join(tracked, "escape")))
+	require.NoError(t, os.Symlink(filepath.Join(tracked, "sub"), filepath.Join(outside, "into-tracked")))
+	require.NoError(t, os.Mkdir(filepath.Join(tracked, "sub"), 0o755))
+
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: "/somewhere/else"}},
+		Commands: []config.CommandPolicy{
+			{Name: "touch", MaxArgs: 2},
+			{Name: "mkdir", AllowedFlags: []string{"-p"}},
+			{Name: "echo", AnyPath: true},
+		},
+	}
+	// the tracker's live watches take precedence over cfg.Directories
+	executor := New(slog.Default(), cfg, staticWatches{{Path: tracked}}, nil).(*commandExecutor)
+
+	tests := []struct {
+		name          string
+		command       string
+		expectedError string
+	}{
+		{"File inside tracked directory", "touch " + filepath.Join(tracked, "a.txt"), ""},
+		{"New nested directories", "mkdir -p " + filepath.Join(tracked, "x", "y"), ""},
+		{"Symlink resolving inside", "touch " + filepath.Join(outside, "into-tracked", "a.txt"), ""},
+		{"Any path allowed", "echo /etc/passwd", ""},
+		{"Outside tracked directory", "touch /etc/anything", "outside the tracked directories"},
+		{"Tracked directory itself", "touch " + tracked, "outside the tracked directories"},
+		{"Symlink escaping", "touch " + filepath.Join(tracked, "escape", "a.txt"), "outside the tracked directories"},
+		{"Parent traversal", "touch " + tracked + "/../a.txt", "must not contain '..'"},
+		{"Relative path", "touch a.txt", "must be absolute"},
+		{"Flag not allowed", "touch -r " + filepath.Join(tracked, "a.txt"), "flag: -r is not allowed"},
+		{"Too many arguments", "touch " + filepath.Join(tracked, "a") + " " + filepath.Join(tracked, "b") + " " + filepath.Join(tracked, "c"), "at most 2 arguments"},
+		{"Not whitelisted", "rm " + filepath.Join(tracked, "a.txt"), "not whitelisted"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := executor.checkCommand(tt.command)
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+// go test -v -cover -run TestCheckPath ./internal/commandexecutor
+func TestCheckPath(t *testing.T) {
+	tracked := t.TempDir()
+	executor := New(slog.Default(), &config.Config{}, staticWatches{{Path: tracked}}, nil)
+
+	assert.NoError(t, executor.CheckPath(filepath.Join(tracked, "restored.txt")))
+
+	err := executor.CheckPath("/etc/passwd")
+	assert.ErrorIs(t, err, ErrCommandRejected)
+	assert.ErrorContains(t, err, "outside the tracked directories")
+}
@@ -0,0 +1,11 @@
+//go:build windows
+
+package commandexecutor
+
+import (
+	"os/exec"
+)
+
+// killProcessGroupOnCancel keeps exec's default of killing the command
+// itself, windows has no process groups to signal
+func killProcessGroupOnCancel(cmd *exec.Cmd) {}
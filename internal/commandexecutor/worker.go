@@ -0,0 +1,75 @@
+package commandexecutor
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// worker runs the jobs of its queue one at a time
+type worker struct {
+	id            int
+	queue         chan string // ids of queued jobs
+	mu            sync.Mutex
+	lastHeartbeat time.Time
+}
+
+func newWorker(id int, queueSize int) *worker {
+	return &worker{
+		id:    id,
+		queue: make(chan string, queueSize),
+	}
+}
+
+func (w *worker) heartbeat() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.lastHeartbeat = time.Now()
+}
+
+func (w *worker) isAlive() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return time.Since(w.lastHeartbeat) < 2*time.Minute
+}
+
+// workerFor picks the worker of an ordering key, the same key always maps to
+// the same worker so its jobs keep their order
+func (f *commandExecutor) workerFor(orderingKey string) *worker {
+	h := fnv.New32a()
+	h.Write([]byte(orderingKey))
+
+	return f.workers[h.Sum32()%uint32(len(f.workers))]
+}
+
+func (f *commandExecutor) drainCommandQueue(w *worker) {
+	for jobID := range w.queue {
+		f.runJob(jobID)
+	}
+}
+
+func (f *commandExecutor) workerThread(ctx context.Context, w *worker) {
+	w.heartbeat()
+	ticker := time.NewTicker(10 * time.Second) // Heartbeat every 10 seconds
+	defer func() {
+		ticker.Stop()
+		f.closeQueue(w)
+		f.drainCommandQueue(w) // process all queued commands before shutdown
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.appLogger.Info("command-executor-shutdown", slog.Int("worker", w.id))
+			return
+		case <-ticker.C:
+			w.heartbeat()
+		case jobID := <-w.queue:
+			f.runJob(jobID)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// CommandPolicy describes a command the executor may run and the arguments it accepts
+type CommandPolicy struct {
+	Name         string   `mapstructure:"name" json:"name" validate:"required"`
+	AllowedFlags []string `mapstructure:"allowed_flags" json:"allowed_flags,omitempty"`
+	MaxArgs      int      `mapstructure:"max_args" json:"max_args" validate:"min=0"` // 0 means no limit
+
+	// By default every argument that is not a flag is a path that must
+	// resolve inside a tracked directory, AnyPath lifts that restriction.
+	AnyPath bool `mapstructure:"any_path" json:"any_path"`
+}
+
+// DefaultCommandPolicies are allowed when config.yaml has no commands list
+func DefaultCommandPolicies() []CommandPolicy {
+	return []CommandPolicy{
+		{Name: "touch", MaxArgs: 10},
+		{Name: "mkdir", AllowedFlags: []string{"-p"}, MaxArgs: 10},
+	}
+}
+
+func loadCommandPolicies() ([]CommandPolicy, error) {
+	var policies []CommandPolicy
+	err := viper.UnmarshalKey("commands", &policies)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding commands: %w", err)
+	}
+	if len(policies) == 0 {
+		return DefaultCommandPolicies(), nil
+	}
+
+	seen := make(map[string]bool, len(policies))
+	for _, p := range policies {
+		if seen[p.Name] {
+			return nil, fmt.Errorf("command is configured more than once: %s", p.Name)
+		}
+		seen[p.Name] = true
+
+		for _, flag := range p.AllowedFlags {
+			if !strings.HasPrefix(flag, "-") {
+				return nil, fmt.Errorf("invalid flag for command %s: %s", p.Name, flag)
+			}
+		}
+	}
+
+	return policies, nil
+}
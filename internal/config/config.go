@@ -2,8 +2,6 @@ package config
 
 import (
 	"fmt"
-	"regexp"
-	"strings"
 
 	"github.com/go-playground/validator"
 	"github.com/spf13/viper"
@@ -15,18 +13,102 @@ const (
 	ConfigType = "yaml"
 
 	DefaultHTTPPort = "9000"
+	DefaultDataDir  = "data"
+
+	DefaultMaxCatchupWindow = 24 * 60 * 60 // in seconds
+	DefaultCommandQueueSize = 100
+	DefaultCommandTimeout   = 60 // in seconds
+	DefaultCommandWorkers   = 4
+
+	DefaultReportingBatchSize   = 50
+	DefaultReportingInterval    = 5   // in seconds
+	DefaultReportingMaxBackoff  = 300 // in seconds
+	DefaultReportingOutboxLimit = 10000
 
 	LogsDBName         = "logsDB"
 	LogsCollectionName = "logs"
+
+	LogStoreMongo  = "mongo"
+	LogStoreSQLite = "sqlite"
+	LogStoreJSONL  = "jsonl"
+	LogStoreMemory = "memory"
+
+	DefaultLogStoreDriver   = LogStoreMongo
+	DefaultLogStoreCapacity = 10000
+
+	EventSourceOSQuery  = "osquery"
+	EventSourceFSNotify = "fsnotify"
+
+	DefaultEventSource = EventSourceOSQuery
+
+	DefaultBaselineScanInterval = 0 // in seconds, scans only run on demand
+
+	DefaultHashingEnabled = true
+	DefaultHashingMaxSize = 100 * 1024 * 1024 // in bytes
+	DefaultHashingWorkers = 4
+
+	DefaultCoalesceWindow = 0 // in seconds, every event is logged
+
+	DefaultSnapshotMaxFileSize  = 10 * 1024 * 1024   // in bytes
+	DefaultSnapshotMaxVersions  = 10                 // per file
+	DefaultSnapshotMaxAge       = 30 * 24 * 60 * 60  // in seconds
+	DefaultSnapshotMaxTotalSize = 1024 * 1024 * 1024 // in bytes
 )
 
 type Config struct {
-	Directory      string `validate:"required"`
+	Directory      string // first watched directory, kept for single-directory setups
 	CheckFrequency int    `validate:"required,min=1"`
 	ReportingAPI   string `validate:"required,url"`
 	HTTPPort       string `validate:"required"`
-	SocketPath     string `validate:"required"`
-	MongoURI       string `validate:"required"`
+	SocketPath     string // required by the osquery event source
+	MongoURI       string // required by the mongo log store
+	DataDir        string `validate:"required"`
+	EventSource    string `validate:"required,oneof=osquery fsnotify"`
+
+	LogStoreDriver   string `validate:"required,oneof=mongo sqlite jsonl memory"`
+	LogStorePath     string // file of the sqlite and jsonl log stores, defaults to one in DataDir
+	LogStoreCapacity int    `validate:"min=0"` // entries kept by the memory log store, 0 keeps all
+
+	Directories []WatchConfig   `validate:"required,min=1,dive"`
+	Commands    []CommandPolicy `validate:"dive"`
+
+	CommandQueueSize int `validate:"required,min=1"`
+	CommandTimeout   int `validate:"required,min=1"` // in seconds
+	CommandWorkers   int `validate:"required,min=1"`
+
+	// HashingEnabled makes the tracker hash created and updated files
+	// itself when the event source left the sha256 out
+	HashingEnabled bool
+	HashingMaxSize int64 `validate:"min=0"` // in bytes, larger files are not hashed, 0 hashes every file
+	HashingWorkers int   `validate:"required,min=1"`
+	HashingFuzzy   bool  // also compute an ssdeep fuzzy hash
+
+	// CoalesceWindow merges the events of a path within this many seconds
+	// into one entry with their time span and count. 0 logs every event.
+	CoalesceWindow    int  `validate:"min=0"`
+	CoalesceRawEvents bool // keep the merged events on the entry
+
+	// SnapshotsEnabled keeps a copy of tracked files each time they are
+	// created or updated, so earlier versions can be diffed and restored
+	SnapshotsEnabled     bool
+	SnapshotsDir         string // defaults to a directory in DataDir
+	SnapshotMaxFileSize  int64  `validate:"min=0"` // in bytes, larger files are not copied, 0 copies every file
+	SnapshotMaxVersions  int    `validate:"min=0"` // kept per file, 0 keeps all
+	SnapshotMaxAge       int    `validate:"min=0"` // in seconds, 0 keeps versions however old
+	SnapshotMaxTotalSize int64  `validate:"min=0"` // in bytes, the oldest versions go first past it, 0 for no limit
+
+	// BaselineScanInterval is how often (in seconds) the watched files are
+	// re-scanned for drift the event source missed. 0 only scans on demand.
+	BaselineScanInterval int `validate:"min=0"`
+
+	// MaxCatchupWindow caps how far back (in seconds) the tracker resumes
+	// from a saved checkpoint. 0 resumes from the checkpoint however old.
+	MaxCatchupWindow int `validate:"min=0"`
+
+	ReportingBatchSize   int `validate:"required,min=1"`
+	ReportingInterval    int `validate:"required,min=1"`
+	ReportingMaxBackoff  int `validate:"required,min=1"`
+	ReportingOutboxLimit int `validate:"required,min=1"`
 }
 
 func LoadConfig(name, path string) (*Config, error) {
@@ -35,25 +117,86 @@ func LoadConfig(name, path string) (*Config, error) {
 	viper.AddConfigPath(path)
 
 	viper.SetDefault("http_port", DefaultHTTPPort)
+	viper.SetDefault("data_dir", DefaultDataDir)
+	viper.SetDefault("event_source", DefaultEventSource)
+	viper.SetDefault("log_store.driver", DefaultLogStoreDriver)
+	viper.SetDefault("log_store.capacity", DefaultLogStoreCapacity)
+	viper.SetDefault("max_catchup_window", DefaultMaxCatchupWindow)
+	viper.SetDefault("baseline_scan_interval", DefaultBaselineScanInterval)
+	viper.SetDefault("hashing.enabled", DefaultHashingEnabled)
+	viper.SetDefault("hashing.max_size", DefaultHashingMaxSize)
+	viper.SetDefault("hashing.workers", DefaultHashingWorkers)
+	viper.SetDefault("coalescing.window", DefaultCoalesceWindow)
+	viper.SetDefault("snapshots.max_file_size", DefaultSnapshotMaxFileSize)
+	viper.SetDefault("snapshots.max_versions", DefaultSnapshotMaxVersions)
+	viper.SetDefault("snapshots.max_age", DefaultSnapshotMaxAge)
+	viper.SetDefault("snapshots.max_total_size", DefaultSnapshotMaxTotalSize)
+	viper.SetDefault("command_queue_size", DefaultCommandQueueSize)
+	viper.SetDefault("command_timeout", DefaultCommandTimeout)
+	viper.SetDefault("command_workers", DefaultCommandWorkers)
+	viper.SetDefault("reporting_batch_size", DefaultReportingBatchSize)
+	viper.SetDefault("reporting_interval", DefaultReportingInterval)
+	viper.SetDefault("reporting_max_backoff", DefaultReportingMaxBackoff)
+	viper.SetDefault("reporting_outbox_limit", DefaultReportingOutboxLimit)
 
 	err := viper.ReadInConfig()
 	if err != nil {
 		return nil, fmt.Errorf("error loading config.yaml: %w", err)
 	}
 
-	sanitizedDir := strings.ReplaceAll(viper.GetString("directory"), "'", "''")
-	validPath := regexp.MustCompile(`^[a-zA-Z0-9/_-]+$`)
-	if !validPath.MatchString(sanitizedDir) {
-		return nil, fmt.Errorf("invalid directory format")
+	watches, err := loadWatches()
+	if err != nil {
+		return nil, err
+	}
+
+	policies, err := loadCommandPolicies()
+	if err != nil {
+		return nil, err
 	}
 
 	cfg := &Config{
-		Directory:      sanitizedDir,
+		Directory:      watches[0].Path,
 		CheckFrequency: viper.GetInt("check_frequency"),
 		ReportingAPI:   viper.GetString("reporting_api"),
 		HTTPPort:       viper.GetString("http_port"),
 		SocketPath:     viper.GetString("socket_path"),
 		MongoURI:       viper.GetString("mongo_uri"),
+		DataDir:        viper.GetString("data_dir"),
+		EventSource:    viper.GetString("event_source"),
+
+		LogStoreDriver:   viper.GetString("log_store.driver"),
+		LogStorePath:     viper.GetString("log_store.path"),
+		LogStoreCapacity: viper.GetInt("log_store.capacity"),
+
+		Directories: watches,
+		Commands:    policies,
+
+		CommandQueueSize: viper.GetInt("command_queue_size"),
+		CommandTimeout:   viper.GetInt("command_timeout"),
+		CommandWorkers:   viper.GetInt("command_workers"),
+
+		HashingEnabled: viper.GetBool("hashing.enabled"),
+		HashingMaxSize: viper.GetInt64("hashing.max_size"),
+		HashingWorkers: viper.GetInt("hashing.workers"),
+		HashingFuzzy:   viper.GetBool("hashing.fuzzy"),
+
+		CoalesceWindow:    viper.GetInt("coalescing.window"),
+		CoalesceRawEvents: viper.GetBool("coalescing.raw_events"),
+
+		SnapshotsEnabled:     viper.GetBool("snapshots.enabled"),
+		SnapshotsDir:         viper.GetString("snapshots.dir"),
+		SnapshotMaxFileSize:  viper.GetInt64("snapshots.max_file_size"),
+		SnapshotMaxVersions:  viper.GetInt("snapshots.max_versions"),
+		SnapshotMaxAge:       viper.GetInt("snapshots.max_age"),
+		SnapshotMaxTotalSize: viper.GetInt64("snapshots.max_total_size"),
+
+		BaselineScanInterval: viper.GetInt("baseline_scan_interval"),
+		MaxCatchupWindow:     viper.GetInt("max_catchup_window"),
+
+		ReportingBatchSize:   viper.GetInt("reporting_batch_size"),
+		ReportingInterval:    viper.GetInt("reporting_interval"),
+		ReportingMaxBackoff:  viper.GetInt("reporting_max_backoff"),
+		ReportingOutboxLimit: viper.GetInt("reporting_outbox_limit"),
 	}
 
 	validate := validator.New()
@@ -61,6 +204,12 @@ func LoadConfig(name, path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("error validating config: %w", err)
 	}
+	if cfg.LogStoreDriver == LogStoreMongo && cfg.MongoURI == "" {
+		return nil, fmt.Errorf("error validating config: mongo_uri is required by the %s log store", LogStoreMongo)
+	}
+	if cfg.EventSource == EventSourceOSQuery && cfg.SocketPath == "" {
+		return nil, fmt.Errorf("error validating config: socket_path is required by the %s event source", EventSourceOSQuery)
+	}
 
 	return cfg, nil
 }
@@ -0,0 +1,146 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// WatchActions are the actions reported by osquery's file_events table
+var WatchActions = []string{
+	"CREATED",
+	"UPDATED",
+	"DELETED",
+	"ATTRIBUTES_MODIFIED",
+	"MOVED_FROM",
+	"MOVED_TO",
+	"OPENED",
+	"ACCESSED",
+}
+
+// WatchConfig describes a watched directory and which of its events are tracked
+type WatchConfig struct {
+	Path      string   `json:"path" validate:"required"`
+	Recursive bool     `json:"recursive"`
+	Include   []string `json:"include,omitempty"` // glob patterns, matched against the file name or path relative to Path
+	Exclude   []string `json:"exclude,omitempty"` // glob patterns, checked after Include
+	Actions   []string `json:"actions,omitempty"` // empty tracks every action
+}
+
+// watchEntry mirrors WatchConfig as written in config.yaml, recursive is a
+// pointer so that it can default to true when left out.
+type watchEntry struct {
+	Path      string   `mapstructure:"path"`
+	Recursive *bool    `mapstructure:"recursive"`
+	Include   []string `mapstructure:"include"`
+	Exclude   []string `mapstructure:"exclude"`
+	Actions   []string `mapstructure:"actions"`
+}
+
+// ValidateWatch checks and normalizes a watch the same way for config.yaml
+// and for watches added at runtime.
+func ValidateWatch(w *WatchConfig) error {
+	dir, err := cleanPath(w.Path)
+	if err != nil {
+		return fmt.Errorf("invalid directory format: %w", err)
+	}
+	w.Path = dir
+
+	for _, pattern := range append(append([]string{}, w.Include...), w.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid glob pattern: %s", pattern)
+		}
+	}
+
+	for i, action := range w.Actions {
+		action = strings.ToUpper(strings.TrimSpace(action))
+		if !isWatchAction(action) {
+			return fmt.Errorf("invalid action: %s", w.Actions[i])
+		}
+		w.Actions[i] = action
+	}
+
+	return nil
+}
+
+// cleanPath expands a leading ~ and returns the cleaned absolute path. Any
+// character is allowed since osquery queries are built with escaped values.
+func cleanPath(dir string) (string, error) {
+	if dir == "" {
+		return "", fmt.Errorf("path is empty")
+	}
+	if !utf8.ValidString(dir) || strings.ContainsRune(dir, 0) {
+		return "", fmt.Errorf("path contains invalid characters")
+	}
+
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("error expanding ~: %w", err)
+		}
+		dir = filepath.Join(home, strings.TrimPrefix(dir, "~"))
+	}
+
+	if !filepath.IsAbs(dir) {
+		return "", fmt.Errorf("path must be absolute")
+	}
+
+	return filepath.Clean(dir), nil
+}
+
+func isWatchAction(action string) bool {
+	for _, a := range WatchActions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// loadWatches reads the directories list and folds the legacy single
+// directory key into it as a recursive watch.
+func loadWatches() ([]WatchConfig, error) {
+	var entries []watchEntry
+	err := viper.UnmarshalKey("directories", &entries)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding directories: %w", err)
+	}
+
+	watches := make([]WatchConfig, 0, len(entries)+1)
+	if dir := viper.GetString("directory"); dir != "" || len(entries) == 0 {
+		watches = append(watches, WatchConfig{Path: dir, Recursive: true})
+	}
+
+	for _, e := range entries {
+		recursive := true
+		if e.Recursive != nil {
+			recursive = *e.Recursive
+		}
+		watches = append(watches, WatchConfig{
+			Path:      e.Path,
+			Recursive: recursive,
+			Include:   e.Include,
+			Exclude:   e.Exclude,
+			Actions:   e.Actions,
+		})
+	}
+
+	seen := make(map[string]bool, len(watches))
+	for i := range watches {
+		err := ValidateWatch(&watches[i])
+		if err != nil {
+			return nil, err
+		}
+		if seen[watches[i].Path] {
+			return nil, fmt.Errorf("directory is watched more than once: %s", watches[i].Path)
+		}
+		seen[watches[i].Path] = true
+	}
+
+	return watches, nil
+}
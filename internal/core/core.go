@@ -2,28 +2,57 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"log/slog"
 	"os"
+	"reflect"
+	"sync"
 	"time"
 
 	"github.com/danielboakye/filechangestracker/internal/commandexecutor"
 	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
 	"github.com/danielboakye/filechangestracker/internal/filechangestracker"
 	"github.com/danielboakye/filechangestracker/internal/httpserver"
 	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/danielboakye/filechangestracker/internal/reporter"
+	"github.com/danielboakye/filechangestracker/internal/snapshot"
 	"github.com/danielboakye/filechangestracker/pkg/osquerymanager"
 	"github.com/osquery/osquery-go"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// healthEmitInterval is how often the health is checked for changes to emit
+// to the frontend
+const healthEmitInterval = 5 * time.Second
+
+// healthEvent is the runtime event carrying a httpserver.HealthCheckResponse,
+// the bus topics are emitted under their own names
+const healthEvent = "health"
+
+var errNotStarted = errors.New("app is not started")
+
 type App struct {
+	wailsCtx  context.Context // nil unless running in Wails
 	ctx       context.Context
 	cancel    context.CancelFunc
 	apiServer *httpserver.Server
 	executor  commandexecutor.CommandExecutor
 	tracker   filechangestracker.FileChangesTracker
 	logStore  mongolog.LogStore
+	reporter  reporter.Reporter
+	snapshots snapshot.Store // nil when snapshots are disabled
+
+	mu      sync.RWMutex
+	handler *httpserver.Handler // nil while stopped
+}
+
+// OnStartup is called by Wails before the frontend loads, its context is
+// needed to emit runtime events
+func (a *App) OnStartup(ctx context.Context) {
+	a.wailsCtx = ctx
 }
 
 func (a *App) Start() {
@@ -36,34 +65,57 @@ func (a *App) Start() {
 		log.Fatalf("error loading config: %v", err)
 	}
 
-	logStore, err := mongolog.NewMongoLogStore(a.ctx, cfg.MongoURI, config.LogsDBName, config.LogsCollectionName)
+	logStore, err := mongolog.NewLogStore(a.ctx, cfg)
 	if err != nil {
-		log.Fatalf("failed to start mongo: %v", err)
+		log.Fatalf("failed to open %s log store: %v", cfg.LogStoreDriver, err)
 	}
 
 	appLogger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelDebug,
 	}))
 
-	executor := commandexecutor.New(appLogger, cfg)
-	if err := executor.Start(a.ctx); err != nil {
-		log.Fatalf("failed to start command executor: %v", err)
+	eventReporter, err := reporter.New(appLogger, cfg)
+	if err != nil {
+		log.Fatalf("failed to create reporter: %v", err)
+	}
+	if err := eventReporter.Start(a.ctx); err != nil {
+		log.Fatalf("failed to start reporter: %v", err)
 	}
 
-	osqueryClient, err := osquery.NewClient(cfg.SocketPath, 10*time.Second)
+	source, err := newEventSource(cfg, appLogger)
 	if err != nil {
-		log.Fatalf("Error creating osquery client: %v", err)
+		log.Fatalf("failed to create %s event source: %v", cfg.EventSource, err)
 	}
-	osqueryManager := osquerymanager.New(osqueryClient)
 
-	tracker := filechangestracker.New(appLogger, cfg, osqueryManager, logStore)
+	events := eventbus.New(appLogger)
+
+	tracker := filechangestracker.New(appLogger, cfg, source, logStore, eventReporter, events)
 	if err := tracker.Start(a.ctx); err != nil {
 		log.Fatalf("failed to start tracker: %v", err)
 	}
 
-	appLogger.Info("started-tracker-on-directory", slog.String("directory", cfg.Directory))
+	for _, w := range cfg.Directories {
+		appLogger.Info("started-tracker-on-directory", slog.String("directory", w.Path), slog.Bool("recursive", w.Recursive))
+	}
+
+	executor := commandexecutor.New(appLogger, cfg, tracker, events)
+	if err := executor.Start(a.ctx); err != nil {
+		log.Fatalf("failed to start command executor: %v", err)
+	}
 
-	handler := httpserver.NewHandler(tracker, executor)
+	// restores go through the executor's path checks
+	var snapshots snapshot.Store
+	if cfg.SnapshotsEnabled {
+		snapshots, err = snapshot.New(appLogger, cfg, events, executor)
+		if err != nil {
+			log.Fatalf("failed to open snapshot store: %v", err)
+		}
+		if err := snapshots.Start(a.ctx); err != nil {
+			log.Fatalf("failed to start snapshot store: %v", err)
+		}
+	}
+
+	handler := httpserver.NewHandler(tracker, executor, eventReporter, events, snapshots)
 	router := handler.RegisterRoutes()
 
 	addr := fmt.Sprintf(":%s", cfg.HTTPPort)
@@ -76,17 +128,120 @@ func (a *App) Start() {
 	a.tracker = tracker
 	a.apiServer = apiServer
 	a.logStore = logStore
+	a.reporter = eventReporter
+	a.snapshots = snapshots
+
+	a.mu.Lock()
+	a.handler = handler
+	a.mu.Unlock()
+
+	if a.wailsCtx != nil {
+		go a.emitEvents(a.ctx, events, handler)
+	}
 }
 
 func (a *App) Stop() {
+	a.mu.Lock()
+	a.handler = nil
+	a.mu.Unlock()
+
 	a.apiServer.Stop(a.ctx)
 	a.executor.Stop(a.ctx)
 	a.tracker.Stop(a.ctx)
+	if a.snapshots != nil {
+		a.snapshots.Stop(a.ctx)
+	}
+	a.reporter.Stop(a.ctx)
 	a.logStore.Close(a.ctx)
 	a.cancel()
 	fmt.Println("app stopped!")
 }
 
+// newEventSource creates the source selected by event_source, only osquery
+// needs osqueryd running
+func newEventSource(cfg *config.Config, appLogger *slog.Logger) (filechangestracker.EventSource, error) {
+	if cfg.EventSource == config.EventSourceFSNotify {
+		return filechangestracker.NewFSNotifySource(appLogger)
+	}
+
+	osqueryClient, err := osquery.NewClient(cfg.SocketPath, 10*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("error creating osquery client: %w", err)
+	}
+
+	return filechangestracker.NewOSQuerySource(osquerymanager.New(osqueryClient)), nil
+}
+
+// emitEvents forwards the bus events and health changes to the frontend
+// until ctx is done
+func (a *App) emitEvents(ctx context.Context, events eventbus.Bus, handler *httpserver.Handler) {
+	sub := events.Subscribe(nil)
+	defer func() { events.Unsubscribe(sub) }()
+
+	ticker := time.NewTicker(healthEmitInterval)
+	defer ticker.Stop()
+
+	var lastHealth httpserver.HealthCheckResponse
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			health := handler.Health()
+			if !reflect.DeepEqual(health, lastHealth) {
+				runtime.EventsEmit(a.wailsCtx, healthEvent, health)
+				lastHealth = health
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// evicted, the frontend reloads the logs when it needs them
+				sub = events.Subscribe(nil)
+				continue
+			}
+			runtime.EventsEmit(a.wailsCtx, event.Topic, event.Data)
+		}
+	}
+}
+
+// GetLogs returns a page of logs, like GET /v1/logs
+func (a *App) GetLogs(req httpserver.LogsRequest) (httpserver.LogsResponse, error) {
+	handler, err := a.api()
+	if err != nil {
+		return httpserver.LogsResponse{}, err
+	}
+	return handler.Logs(a.ctx, req)
+}
+
+// GetHealth returns the health status, like GET /v1/health
+func (a *App) GetHealth() (httpserver.HealthCheckResponse, error) {
+	handler, err := a.api()
+	if err != nil {
+		return httpserver.HealthCheckResponse{}, err
+	}
+	return handler.Health(), nil
+}
+
+// SubmitCommands queues commands, like POST /v1/commands
+func (a *App) SubmitCommands(orderingKey string, commands []string) ([]commandexecutor.Job, error) {
+	if _, err := a.api(); err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, errors.New("no commands submitted")
+	}
+	return a.executor.AddCommands(orderingKey, commands)
+}
+
+func (a *App) api() (*httpserver.Handler, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.handler == nil {
+		return nil, errNotStarted
+	}
+	return a.handler, nil
+}
+
 func New() *App {
 	return &App{}
 }
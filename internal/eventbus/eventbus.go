@@ -0,0 +1,103 @@
+package eventbus
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before
+// it is evicted
+const subscriberBuffer = 256
+
+const (
+	TopicFileChange = "file_change" // Data is a mongolog.LogEntry
+	TopicJob        = "job"         // Data is a commandexecutor.Job
+)
+
+// Event is published to every subscriber whose match function accepts it
+type Event struct {
+	Topic string
+	ID    string // lets a subscriber resume after the event, e.g. a log cursor
+	Data  interface{}
+}
+
+// Bus fans events out to subscribers. Publishing never blocks, a subscriber
+// that can't keep up is evicted and its events channel closed.
+type Bus interface {
+	Publish(event Event)
+	Subscribe(match func(event Event) bool) *Subscription
+	Unsubscribe(sub *Subscription)
+}
+
+// Subscription receives the events its match function accepts
+type Subscription struct {
+	events chan Event
+	match  func(event Event) bool
+}
+
+// Events is closed when the subscription is removed or evicted
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+type bus struct {
+	appLogger   *slog.Logger
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+}
+
+func New(appLogger *slog.Logger) Bus {
+	return &bus{
+		appLogger:   appLogger,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+func (b *bus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for sub := range b.subscribers {
+		if sub.match != nil && !sub.match(event) {
+			continue
+		}
+
+		select {
+		case sub.events <- event:
+		default:
+			b.appLogger.Warn("evicted-slow-subscriber", slog.String("topic", event.Topic))
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscriber, a nil match receives every event
+func (b *bus) Subscribe(match func(event Event) bool) *Subscription {
+	sub := &Subscription{
+		events: make(chan Event, subscriberBuffer),
+		match:  match,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers[sub] = struct{}{}
+	return sub
+}
+
+func (b *bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.remove(sub)
+}
+
+// remove closes the events of sub once, the caller must hold mu
+func (b *bus) remove(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(b.subscribers, sub)
+	close(sub.events)
+}
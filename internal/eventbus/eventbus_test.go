@@ -0,0 +1,52 @@
+package eventbus
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -cover ./internal/eventbus/...
+
+// go test -v -cover -run TestPublish ./internal/eventbus
+func TestPublish(t *testing.T) {
+	b := New(slog.Default())
+
+	all := b.Subscribe(nil)
+	files := b.Subscribe(func(e Event) bool { return e.Topic == TopicFileChange })
+
+	b.Publish(Event{Topic: TopicFileChange, ID: "1"})
+	b.Publish(Event{Topic: "other", ID: "2"})
+
+	assert.Len(t, all.Events(), 2)
+	assert.Len(t, files.Events(), 1)
+	assert.Equal(t, "1", (<-files.Events()).ID)
+
+	b.Unsubscribe(files)
+	b.Unsubscribe(files) // no-op
+	_, open := <-files.Events()
+	assert.False(t, open)
+}
+
+// go test -v -cover -run TestPublish_EvictsSlowSubscriber ./internal/eventbus
+func TestPublish_EvictsSlowSubscriber(t *testing.T) {
+	b := New(slog.Default())
+
+	slow := b.Subscribe(nil)
+	fast := b.Subscribe(nil)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(Event{Topic: TopicFileChange})
+		<-fast.Events()
+	}
+
+	received := 0
+	for range slow.Events() { // closed once evicted
+		received++
+	}
+	assert.Equal(t, subscriberBuffer, received)
+
+	b.Publish(Event{Topic: TopicFileChange})
+	assert.Len(t, fast.Events(), 1) // others keep receiving
+}
@@ -0,0 +1,464 @@
+package filechangestracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScanInProgress = errors.New("a baseline scan is already running")
+	ErrScanNotFound   = errors.New("scan not found")
+)
+
+// states of a ScanStatus
+const (
+	ScanRunning   = "running"
+	ScanSucceeded = "succeeded"
+	ScanFailed    = "failed"
+)
+
+// ScanResult summarizes a baseline scan. The first scan only records the
+// baseline, later ones count the drift they logged.
+type ScanResult struct {
+	Files      int       `json:"files"`
+	Created    int       `json:"created"`
+	Updated    int       `json:"updated"`
+	Deleted    int       `json:"deleted"`
+	Baseline   bool      `json:"baseline"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// ScanStatus is the state of the last scan, Result is set once it succeeded
+type ScanStatus struct {
+	ID        string      `json:"id"`
+	State     string      `json:"state"`
+	Error     string      `json:"error,omitempty"`
+	Result    *ScanResult `json:"result,omitempty"`
+	StartedAt time.Time   `json:"started_at"`
+}
+
+// restoreBaseline loads the baseline of an earlier run so the next scan
+// reports what changed while the app was down.
+func (f *fileChangesTracker) restoreBaseline(ctx context.Context) error {
+	saved, ok, err := f.logStore.LoadBaseline(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading baseline: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	f.scanMu.Lock()
+	f.baseline = &saved
+	f.scanMu.Unlock()
+
+	f.observedMu.Lock()
+	f.observed = make(map[string]bool)
+	f.observedMu.Unlock()
+
+	return nil
+}
+
+// scanThread scans right away, which records the baseline or the drift
+// since the app last ran, then again every BaselineScanInterval
+func (f *fileChangesTracker) scanThread(ctx context.Context) {
+	interval := time.Duration(f.config.BaselineScanInterval) * time.Second
+	for {
+		_, err := f.Scan(ctx)
+		if err != nil && ctx.Err() == nil && !errors.Is(err, ErrScanInProgress) {
+			f.appLogger.Error("error-scanning-baseline", slog.String("error", err.Error()))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// StartScan runs a scan in the background, its progress is read with
+// GetScan. The scan runs until the tracker stops.
+func (f *fileChangesTracker) StartScan() (ScanStatus, error) {
+	if !f.scanMu.TryLock() {
+		return ScanStatus{}, ErrScanInProgress
+	}
+
+	status := f.beginScan()
+	go func() {
+		defer f.scanMu.Unlock()
+		_, err := f.runScan(f.scanCtx, status.ID)
+		if err != nil {
+			f.appLogger.Error("error-scanning-baseline", slog.String("error", err.Error()))
+		}
+	}()
+
+	return status, nil
+}
+
+// GetScan returns the last scan when id is its ID or "latest", only the
+// last scan is kept
+func (f *fileChangesTracker) GetScan(id string) (ScanStatus, error) {
+	f.lastScanMu.Lock()
+	defer f.lastScanMu.Unlock()
+
+	if f.lastScan.ID == "" || (id != "latest" && id != f.lastScan.ID) {
+		return ScanStatus{}, ErrScanNotFound
+	}
+
+	return f.lastScan, nil
+}
+
+// Scan runs a scan and waits for it
+func (f *fileChangesTracker) Scan(ctx context.Context) (ScanResult, error) {
+	if !f.scanMu.TryLock() {
+		return ScanResult{}, ErrScanInProgress
+	}
+	defer f.scanMu.Unlock()
+
+	return f.runScan(ctx, f.beginScan().ID)
+}
+
+func (f *fileChangesTracker) beginScan() ScanStatus {
+	f.lastScanMu.Lock()
+	defer f.lastScanMu.Unlock()
+
+	f.lastScan = ScanStatus{
+		ID:        uuid.NewString(),
+		State:     ScanRunning,
+		StartedAt: time.Now(),
+	}
+	return f.lastScan
+}
+
+// runScan runs scan id and records how it ended, scanMu is held
+func (f *fileChangesTracker) runScan(ctx context.Context, id string) (ScanResult, error) {
+	result, err := f.scan(ctx)
+
+	f.lastScanMu.Lock()
+	defer f.lastScanMu.Unlock()
+	if f.lastScan.ID == id {
+		if err != nil {
+			f.lastScan.State = ScanFailed
+			f.lastScan.Error = err.Error()
+		} else {
+			f.lastScan.State = ScanSucceeded
+			f.lastScan.Result = &result
+		}
+	}
+
+	return result, err
+}
+
+// scan walks the watched directories and hashes their files. Against the
+// previous baseline, files created, updated or deleted without the event
+// source logging them are logged as drift.
+func (f *fileChangesTracker) scan(ctx context.Context) (ScanResult, error) {
+	result := ScanResult{StartedAt: time.Now()}
+	watches := f.currentWatches()
+
+	current := &mongolog.Baseline{
+		Files:     make(map[string]mongolog.FileState),
+		ScannedAt: result.StartedAt,
+	}
+	for _, w := range watches {
+		err := f.scanWatch(ctx, w, current.Files)
+		if err != nil {
+			return ScanResult{}, fmt.Errorf("error scanning %s: %w", w.Path, err)
+		}
+		current.Roots = append(current.Roots, w.root)
+	}
+	result.Files = len(current.Files)
+
+	// paths the event source logged since the last scan are its to report,
+	// including changes made while this scan walked them
+	observed := f.takeObserved()
+	if f.baseline == nil {
+		result.Baseline = true
+	} else {
+		changes := drift(f.baseline, current, observed, watches, result.StartedAt)
+		err := f.logChanges(ctx, changes)
+		if err != nil {
+			f.returnObserved(observed)
+			return ScanResult{}, fmt.Errorf("error logging drift: %w", err)
+		}
+
+		for _, row := range changes {
+			switch row["action"] {
+			case "CREATED":
+				result.Created++
+			case "UPDATED":
+				result.Updated++
+			case "DELETED":
+				result.Deleted++
+			}
+		}
+	}
+
+	f.saveBaseline(ctx, current)
+
+	result.FinishedAt = time.Now()
+	f.appLogger.Info("baseline-scan-finished",
+		slog.Int("files", result.Files),
+		slog.Int("created", result.Created),
+		slog.Int("updated", result.Updated),
+		slog.Int("deleted", result.Deleted),
+		slog.Duration("duration", result.FinishedAt.Sub(result.StartedAt)),
+	)
+
+	return result, nil
+}
+
+// saveBaseline writes the files that changed since the previous baseline to
+// the log store. Failures are logged since the one in memory is still
+// correct, the next scan then writes it whole.
+func (f *fileChangesTracker) saveBaseline(ctx context.Context, current *mongolog.Baseline) {
+	update := baselineUpdate(f.baseline, current, f.baselineDirty)
+	f.baseline = current
+
+	err := f.logStore.UpdateBaseline(ctx, update)
+	f.baselineDirty = err != nil
+	if err != nil {
+		f.appLogger.Error("error-saving-baseline", slog.String("error", err.Error()))
+	}
+}
+
+// baselineUpdate returns what changed from previous to current, or all of
+// current when previous is nil or the stored baseline is not known to
+// match it
+func baselineUpdate(previous, current *mongolog.Baseline, replace bool) mongolog.BaselineUpdate {
+	update := mongolog.BaselineUpdate{
+		Replace:   replace || previous == nil,
+		Set:       make(map[string]mongolog.FileState),
+		Roots:     current.Roots,
+		ScannedAt: current.ScannedAt,
+	}
+	if update.Replace {
+		update.Set = current.Files
+		return update
+	}
+
+	for path, state := range current.Files {
+		if old, ok := previous.Files[path]; !ok || old != state {
+			update.Set[path] = state
+		}
+	}
+	for path := range previous.Files {
+		if _, ok := current.Files[path]; !ok {
+			update.Deleted = append(update.Deleted, path)
+		}
+	}
+
+	return update
+}
+
+// scanWatch records the files below w that pass its path rules, files
+// already found under an earlier watch are left to it
+func (f *fileChangesTracker) scanWatch(ctx context.Context, w watch, files map[string]mongolog.FileState) error {
+	err := filepath.WalkDir(w.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == w.root {
+				return err
+			}
+			f.appLogger.Warn("error-scanning-path", slog.String("path", path), slog.String("error", err.Error()))
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			if path != w.root && !w.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !w.matchesPath(path) {
+			return nil
+		}
+		if _, ok := files[path]; ok {
+			return nil
+		}
+
+		state, err := readFileState(path, f.config.HashingMaxSize)
+		if err != nil {
+			// removed or unreadable since it was listed
+			f.appLogger.Warn("error-scanning-path", slog.String("path", path), slog.String("error", err.Error()))
+			return nil
+		}
+		state.Watch = w.root
+		files[path] = state
+
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		// a missing directory has no files, the ones it had show as deleted
+		return nil
+	}
+
+	return err
+}
+
+// readFileState stats and hashes the file, files over maxSize are not
+// hashed and only compared by their size, mode, owner and mtime. maxSize 0
+// hashes files of any size.
+func readFileState(path string, maxSize int64) (mongolog.FileState, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return mongolog.FileState{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return mongolog.FileState{}, err
+	}
+
+	uid, gid := fileOwner(info)
+	state := mongolog.FileState{
+		Size:  info.Size(),
+		Mode:  fmt.Sprintf("%04o", info.Mode().Perm()),
+		UID:   uid,
+		GID:   gid,
+		MTime: info.ModTime().Unix(),
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return state, nil
+	}
+
+	hash := sha256.New()
+	_, err = io.Copy(hash, file)
+	if err != nil {
+		return mongolog.FileState{}, err
+	}
+	state.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	return state, nil
+}
+
+// drift compares a scan with the previous baseline and returns rows for the
+// differences the event source did not log. Files of watches added since
+// the previous scan are new to the baseline rather than created, and files
+// of removed watches are no longer tracked rather than deleted.
+func drift(previous, current *mongolog.Baseline, observed map[string]bool, watches []watch, now time.Time) []map[string]string {
+	scanned := make(map[string]bool, len(previous.Roots))
+	for _, root := range previous.Roots {
+		scanned[root] = true
+	}
+	byRoot := make(map[string]watch, len(watches))
+	for _, w := range watches {
+		byRoot[w.root] = w
+	}
+
+	var paths []string
+	actions := make(map[string]string)
+	for path, state := range current.Files {
+		if observed[path] || !scanned[state.Watch] {
+			continue
+		}
+
+		old, ok := previous.Files[path]
+		old.Watch = state.Watch
+		switch {
+		case !ok:
+			actions[path] = "CREATED"
+		case old != state:
+			actions[path] = "UPDATED"
+		default:
+			continue
+		}
+		paths = append(paths, path)
+	}
+	for path, state := range previous.Files {
+		if _, ok := current.Files[path]; ok || observed[path] {
+			continue
+		}
+		w, ok := byRoot[state.Watch]
+		if !ok || !w.matchesPath(path) {
+			continue
+		}
+		actions[path] = "DELETED"
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	eidPrefix := "scan-" + strconv.FormatInt(now.UnixNano(), 36) + "-"
+	rows := make([]map[string]string, 0, len(paths))
+	for _, path := range paths {
+		state, ok := current.Files[path]
+		if !ok {
+			state = previous.Files[path]
+		}
+
+		row := map[string]string{
+			"target_path": path,
+			"action":      actions[path],
+			"time":        strconv.FormatInt(now.Unix(), 10),
+			"eid":         eidPrefix + strconv.Itoa(len(rows)),
+			"category":    "baseline_scan",
+			"size":        strconv.FormatInt(state.Size, 10),
+			"mode":        state.Mode,
+			"uid":         state.UID,
+			"gid":         state.GID,
+			"mtime":       strconv.FormatInt(state.MTime, 10),
+			"sha256":      state.SHA256,
+		}
+		w := byRoot[state.Watch]
+		if !w.matches(row) {
+			continue
+		}
+		row["watch"] = w.Path
+
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+// observe records the paths the event source logged, scans leave them out
+// of their drift. Nothing is recorded until a baseline exists.
+func (f *fileChangesTracker) observe(rows []map[string]string) {
+	f.observedMu.Lock()
+	defer f.observedMu.Unlock()
+
+	if f.observed == nil {
+		return
+	}
+	for _, row := range rows {
+		f.observed[row["target_path"]] = true
+	}
+}
+
+func (f *fileChangesTracker) takeObserved() map[string]bool {
+	f.observedMu.Lock()
+	defer f.observedMu.Unlock()
+
+	observed := f.observed
+	f.observed = make(map[string]bool)
+	return observed
+}
+
+// returnObserved puts back the paths taken by a scan that failed
+func (f *fileChangesTracker) returnObserved(observed map[string]bool) {
+	f.observedMu.Lock()
+	defer f.observedMu.Unlock()
+
+	for path := range observed {
+		f.observed[path] = true
+	}
+}
@@ -0,0 +1,219 @@
+package filechangestracker
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	osquerymanagermock "github.com/danielboakye/filechangestracker/mocks/osquerymanager"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newScanTracker(t *testing.T, dir string, logStore mongolog.LogStore) *fileChangesTracker {
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+	mockReporter.EXPECT().Enqueue(gomock.Any()).Return(nil).AnyTimes()
+
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: dir, Recursive: true, Exclude: []string{"*.tmp"}}},
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), logStore, mockReporter, eventbus.New(slog.Default()))
+
+	return tracker.(*fileChangesTracker)
+}
+
+// go test -v -cover -run TestScan ./pkg/filechangestracker
+func TestScan(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	require.NoError(os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+	require.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	require.NoError(os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("b"), 0o644))
+	require.NoError(os.WriteFile(filepath.Join(dir, "skip.tmp"), []byte("tmp"), 0o644))
+
+	logStore := mongolog.NewMemoryLogStore(0)
+	tracker := newScanTracker(t, dir, logStore)
+
+	res, err := tracker.Scan(ctx)
+	require.NoError(err)
+	assert.True(res.Baseline)
+	assert.Equal(2, res.Files)
+
+	page, err := tracker.GetLogs(ctx, mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	assert.Empty(page.Items)
+
+	require.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("changed"), 0o644))
+	require.NoError(os.Remove(filepath.Join(dir, "sub", "b.txt")))
+	require.NoError(os.WriteFile(filepath.Join(dir, "c.txt"), []byte("c"), 0o644))
+	require.NoError(os.WriteFile(filepath.Join(dir, "skip.tmp"), []byte("changed"), 0o644))
+
+	// a restarted tracker compares with the baseline in the log store
+	tracker = newScanTracker(t, dir, logStore)
+	require.NoError(tracker.restoreBaseline(ctx))
+
+	res, err = tracker.Scan(ctx)
+	require.NoError(err)
+	assert.False(res.Baseline)
+	assert.Equal(2, res.Files)
+	assert.Equal(1, res.Created)
+	assert.Equal(1, res.Updated)
+	assert.Equal(1, res.Deleted)
+
+	page, err = tracker.GetLogs(ctx, mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	require.Len(page.Items, 3)
+
+	actions := make(map[string]string)
+	for _, entry := range page.Items {
+		assert.Equal("baseline_scan", entry.Details["category"])
+		assert.Equal(dir, entry.Details["watch"])
+		assert.NotEmpty(entry.Details["sha256"])
+		actions[entry.Details["target_path"]] = entry.Details["action"]
+	}
+	assert.Equal(map[string]string{
+		filepath.Join(dir, "a.txt"):        "UPDATED",
+		filepath.Join(dir, "sub", "b.txt"): "DELETED",
+		filepath.Join(dir, "c.txt"):        "CREATED",
+	}, actions)
+
+	res, err = tracker.Scan(ctx)
+	require.NoError(err)
+	assert.Zero(res.Created + res.Updated + res.Deleted)
+}
+
+// go test -v -cover -run TestScan_SkipsObserved ./pkg/filechangestracker
+func TestScan_SkipsObserved(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	require.NoError(os.WriteFile(path, []byte("a"), 0o644))
+
+	tracker := newScanTracker(t, dir, mongolog.NewMemoryLogStore(0))
+
+	_, err := tracker.Scan(ctx)
+	require.NoError(err)
+
+	// the event source logged this change, the scan should not again
+	require.NoError(os.WriteFile(path, []byte("changed"), 0o644))
+	tracker.observe([]map[string]string{{"target_path": path, "action": "UPDATED"}})
+
+	res, err := tracker.Scan(ctx)
+	require.NoError(err)
+	assert.Zero(res.Updated)
+
+	// later drift of the same file is reported
+	require.NoError(os.WriteFile(path, []byte("changed again"), 0o644))
+
+	res, err = tracker.Scan(ctx)
+	require.NoError(err)
+	assert.Equal(1, res.Updated)
+}
+
+// go test -v -cover -run TestScan_MaxSize ./pkg/filechangestracker
+func TestScan_MaxSize(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small.txt")
+	large := filepath.Join(dir, "large.bin")
+	require.NoError(os.WriteFile(small, []byte("a"), 0o644))
+	require.NoError(os.WriteFile(large, make([]byte, 64), 0o644))
+
+	state, err := readFileState(small, 8)
+	require.NoError(err)
+	assert.NotEmpty(state.SHA256)
+
+	// a file over the limit is only compared by its metadata
+	state, err = readFileState(large, 8)
+	require.NoError(err)
+	assert.Empty(state.SHA256)
+	assert.Equal(int64(64), state.Size)
+
+	state, err = readFileState(large, 0)
+	require.NoError(err)
+	assert.NotEmpty(state.SHA256)
+}
+
+// go test -v -cover -run TestBaselineUpdate ./pkg/filechangestracker
+func TestBaselineUpdate(t *testing.T) {
+	assert := assert.New(t)
+
+	a := mongolog.FileState{Watch: "/w", Size: 1, SHA256: "a"}
+	b := mongolog.FileState{Watch: "/w", Size: 2, SHA256: "b"}
+	previous := &mongolog.Baseline{Files: map[string]mongolog.FileState{"/w/a": a, "/w/b": b}}
+
+	changed := a
+	changed.SHA256 = "changed"
+	current := &mongolog.Baseline{
+		Files: map[string]mongolog.FileState{"/w/a": changed, "/w/c": b},
+		Roots: []string{"/w"},
+	}
+
+	// only what changed is written
+	update := baselineUpdate(previous, current, false)
+	assert.False(update.Replace)
+	assert.Equal(map[string]mongolog.FileState{"/w/a": changed, "/w/c": b}, update.Set)
+	assert.Equal([]string{"/w/b"}, update.Deleted)
+
+	update = baselineUpdate(previous, &mongolog.Baseline{Files: previous.Files}, false)
+	assert.Empty(update.Set)
+	assert.Empty(update.Deleted)
+
+	// the first scan and the one after a failed update write every file
+	for _, update := range []mongolog.BaselineUpdate{baselineUpdate(nil, current, false), baselineUpdate(previous, current, true)} {
+		assert.True(update.Replace)
+		assert.Equal(current.Files, update.Set)
+		assert.Empty(update.Deleted)
+	}
+}
+
+// go test -v -cover -run TestStartScan ./pkg/filechangestracker
+func TestStartScan(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	tracker := newScanTracker(t, dir, mongolog.NewMemoryLogStore(0))
+
+	_, err := tracker.GetScan("latest")
+	assert.ErrorIs(err, ErrScanNotFound)
+
+	status, err := tracker.StartScan()
+	require.NoError(err)
+	assert.Equal(ScanRunning, status.State)
+
+	require.Eventually(func() bool {
+		status, err = tracker.GetScan(status.ID)
+		return err == nil && status.State != ScanRunning
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(ScanSucceeded, status.State)
+	require.NotNil(status.Result)
+	assert.True(status.Result.Baseline)
+	assert.Equal(1, status.Result.Files)
+
+	latest, err := tracker.GetScan("latest")
+	require.NoError(err)
+	assert.Equal(status.ID, latest.ID)
+
+	_, err = tracker.GetScan("unknown")
+	assert.ErrorIs(err, ErrScanNotFound)
+}
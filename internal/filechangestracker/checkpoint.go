@@ -0,0 +1,37 @@
+package filechangestracker
+
+import (
+	"fmt"
+	"time"
+)
+
+const checkpointFileName = "checkpoint.json"
+
+// checkpoint is the tracker cursor as stored in the data directory
+type checkpoint struct {
+	LastProcessedTimestamp int64     `json:"last_processed_timestamp"`
+	UpdatedAt              time.Time `json:"updated_at"`
+}
+
+// loadCheckpoint reads the saved cursor, ok is false when none was saved yet.
+func loadCheckpoint(path string) (timestamp int64, ok bool, err error) {
+	var cp checkpoint
+	ok, err = readJSONFile(path, &cp)
+	if err != nil {
+		return 0, false, fmt.Errorf("error loading checkpoint: %w", err)
+	}
+
+	return cp.LastProcessedTimestamp, ok, nil
+}
+
+func saveCheckpoint(path string, timestamp int64) error {
+	err := writeJSONFile(path, checkpoint{
+		LastProcessedTimestamp: timestamp,
+		UpdatedAt:              time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("error saving checkpoint: %w", err)
+	}
+
+	return nil
+}
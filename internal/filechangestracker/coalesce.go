@@ -0,0 +1,142 @@
+package filechangestracker
+
+import (
+	"encoding/json"
+	"strconv"
+)
+
+// maxRawEvents caps the raw events kept on a coalesced entry, count still
+// has every event
+const maxRawEvents = 100
+
+// coalescer merges the events of a path within a window into one entry, an
+// editor saving a file or a browser downloading one otherwise logs each of
+// its writes. Groups are held in memory until their window has passed and
+// the cursor is held at the oldest of them, so events of groups lost to a
+// restart are read again. Once logged, the tracker leaves their events out
+// like any other logged event.
+type coalescer struct {
+	window    int64 // in seconds
+	rawEvents bool
+
+	pending []*group
+	open    map[string]*group // by target_path, the group new events join
+}
+
+type group struct {
+	first  int64
+	last   int64
+	count  int
+	final  map[string]string   // the latest event, the entry is built from it
+	keys   map[string]int64    // of the events merged, to their time
+	events []map[string]string // only kept with rawEvents
+}
+
+func newCoalescer(window int64, rawEvents bool) *coalescer {
+	return &coalescer{
+		window:    window,
+		rawEvents: rawEvents,
+		open:      make(map[string]*group),
+	}
+}
+
+// add puts row in the open group of its path, or starts a new one once the
+// event is past that group's window
+func (c *coalescer) add(row map[string]string) {
+	key := eventKey(row)
+	for _, g := range c.pending {
+		if _, ok := g.keys[key]; ok {
+			return
+		}
+	}
+	eventTime, _ := strconv.ParseInt(row["time"], 10, 64)
+
+	path := row["target_path"]
+	g, ok := c.open[path]
+	if !ok || eventTime-g.first >= c.window {
+		g = &group{first: eventTime, last: eventTime, keys: make(map[string]int64)}
+		c.open[path] = g
+		c.pending = append(c.pending, g)
+	}
+
+	g.keys[key] = eventTime
+	g.count++
+	if c.rawEvents {
+		g.events = append(g.events, row)
+	}
+	if eventTime < g.first {
+		g.first = eventTime
+	}
+	if g.final == nil || eventTime >= g.last {
+		g.last = eventTime
+		g.final = row
+	}
+}
+
+// ready returns the groups whose window has passed by now, they stay
+// pending until commit so a failed write is retried on the next check
+func (c *coalescer) ready(now int64) []*group {
+	var groups []*group
+	for _, g := range c.pending {
+		if now-g.first >= c.window {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// commit drops the logged groups
+func (c *coalescer) commit(groups []*group) {
+	logged := make(map[*group]bool, len(groups))
+	for _, g := range groups {
+		logged[g] = true
+		if c.open[g.final["target_path"]] == g {
+			delete(c.open, g.final["target_path"])
+		}
+	}
+
+	kept := c.pending[:0]
+	for _, g := range c.pending {
+		if !logged[g] {
+			kept = append(kept, g)
+		}
+	}
+	c.pending = kept
+}
+
+// hold returns the cursor to move to instead of next: never past the oldest
+// pending group
+func (c *coalescer) hold(next int64) int64 {
+	for _, g := range c.pending {
+		if g.first < next {
+			next = g.first
+		}
+	}
+
+	return next
+}
+
+// row builds the entry logged for g: the latest event with the time span,
+// the number of events merged and, when kept, the events themselves
+func (g *group) row(rawEvents bool) map[string]string {
+	row := make(map[string]string, len(g.final)+4)
+	for k, v := range g.final {
+		row[k] = v
+	}
+	row["first_time"] = strconv.FormatInt(g.first, 10)
+	row["last_time"] = strconv.FormatInt(g.last, 10)
+	row["count"] = strconv.Itoa(g.count)
+
+	if rawEvents {
+		events := g.events
+		if len(events) > maxRawEvents {
+			events = events[len(events)-maxRawEvents:]
+		}
+		data, err := json.Marshal(events)
+		if err == nil {
+			row["raw_events"] = string(data)
+		}
+	}
+
+	return row
+}
@@ -0,0 +1,120 @@
+package filechangestracker
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	osquerymanagermock "github.com/danielboakye/filechangestracker/mocks/osquerymanager"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestCheckFileChanges_Coalesces ./pkg/filechangestracker
+func TestCheckFileChanges_Coalesces(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+	mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil).Times(1)
+
+	cfg := &config.Config{
+		Directories:       []config.WatchConfig{{Path: "test", Recursive: true}},
+		CoalesceWindow:    60,
+		CoalesceRawEvents: true,
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mongolog.NewMemoryLogStore(0), mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	now := time.Now().Unix()
+	it.lastProcessedTimestamp = now - 300
+	at := func(ago int64) string { return strconv.FormatInt(now-ago, 10) }
+
+	rows := []map[string]string{
+		{"eid": "1", "target_path": "test/report.pdf", "action": "CREATED", "time": at(200)},
+		{"eid": "2", "target_path": "test/report.pdf", "action": "UPDATED", "time": at(199)},
+		{"eid": "3", "target_path": "test/report.pdf", "action": "UPDATED", "time": at(190)},
+		// still within its window
+		{"eid": "4", "target_path": "test/notes.txt", "action": "UPDATED", "time": at(10)},
+	}
+	// the second check reads the same events again
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).DoAndReturn(func(string) ([]map[string]string, error) {
+		res := make([]map[string]string, 0, len(rows))
+		for _, row := range rows {
+			res = append(res, copyDetails(row))
+		}
+		return res, nil
+	}).Times(2)
+
+	require.NoError(it.checkFileChanges(context.Background()))
+	require.NoError(it.checkFileChanges(context.Background()))
+
+	res, err := tracker.GetLogs(context.Background(), mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	require.Len(res.Items, 1)
+
+	details := res.Items[0].Details
+	assert.Equal("test/report.pdf", details["target_path"])
+	assert.Equal("UPDATED", details["action"])
+	assert.Equal("3", details["count"])
+	assert.Equal(at(200), details["first_time"])
+	assert.Equal(at(190), details["last_time"])
+
+	var raw []map[string]string
+	require.NoError(json.Unmarshal([]byte(details["raw_events"]), &raw))
+	require.Len(raw, 3)
+	assert.Equal("CREATED", raw[0]["action"])
+
+	// the cursor waits for the pending group, the logged events before it
+	// are forgotten
+	assert.Equal(now-10, it.lastProcessedTimestamp)
+	assert.Empty(it.logged)
+}
+
+// go test -v -cover -run TestCoalescer ./pkg/filechangestracker
+func TestCoalescer(t *testing.T) {
+	assert := assert.New(t)
+
+	c := newCoalescer(5, false)
+	event := func(eid, action string, eventTime int64) map[string]string {
+		return map[string]string{"eid": eid, "target_path": "a.txt", "action": action, "time": strconv.FormatInt(eventTime, 10)}
+	}
+
+	c.add(event("1", "CREATED", 100))
+	c.add(event("2", "UPDATED", 103))
+	c.add(event("2", "UPDATED", 103)) // read twice
+	// past the window of the first group
+	c.add(event("3", "DELETED", 105))
+
+	assert.Empty(c.ready(104))
+	groups := c.ready(106)
+	require.Len(t, groups, 1)
+	row := groups[0].row(false)
+	assert.Equal("2", row["count"])
+	assert.Equal("UPDATED", row["action"])
+	assert.NotContains(row, "raw_events")
+	// the events themselves are only kept for raw_events
+	assert.Nil(groups[0].events)
+
+	c.commit(groups)
+	assert.Equal(int64(105), c.hold(106))
+
+	groups = c.ready(110)
+	require.Len(t, groups, 1)
+	assert.Equal("1", groups[0].row(false)["count"])
+	assert.Equal("DELETED", groups[0].row(false)["action"])
+
+	c.commit(groups)
+	assert.Equal(int64(110), c.hold(110))
+	assert.Empty(c.pending)
+}
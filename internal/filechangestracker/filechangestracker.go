@@ -5,13 +5,15 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
 	"github.com/danielboakye/filechangestracker/internal/mongolog"
-	"github.com/danielboakye/filechangestracker/pkg/osquerymanager"
+	"github.com/danielboakye/filechangestracker/internal/reporter"
 )
 
 //go:generate mockgen -destination=../../mocks/filechangestracker/mock_filechangestracker.go -package=filechangestrackermock -source=filechangestracker.go
@@ -20,7 +22,15 @@ type FileChangesTracker interface {
 	Stop(ctx context.Context) error
 
 	IsTimerThreadAlive() bool
-	GetLogs(ctx context.Context, limit, offset int64) ([]mongolog.LogEntry, error)
+	GetLogs(ctx context.Context, query mongolog.LogQuery) (mongolog.LogPage, error)
+	GetLogsAfter(ctx context.Context, query mongolog.LogQuery) ([]mongolog.LogEntry, error)
+
+	ListWatches() []config.WatchConfig
+	AddWatch(w config.WatchConfig) (config.WatchConfig, error)
+	RemoveWatch(path string) error
+
+	StartScan() (ScanStatus, error)
+	GetScan(id string) (ScanStatus, error)
 }
 
 type fileChangesTracker struct {
@@ -28,37 +38,153 @@ type fileChangesTracker struct {
 	config                 *config.Config
 	timerLastHeartbeat     time.Time
 	mu                     sync.Mutex
-	osqueryManager         osquerymanager.OSQueryManager
+	source                 EventSource
 	lastProcessedTimestamp int64
 	logStore               mongolog.LogStore
+	reporter               reporter.Reporter
+	events                 eventbus.Bus
+	watchesMu              sync.RWMutex
+	watches                []watch
+	scanMu                 sync.Mutex
+	scanCtx                context.Context    // scans started by StartScan run until it is done
+	baseline               *mongolog.Baseline // nil until the first scan
+	baselineDirty          bool               // the stored baseline may not match baseline, the next scan replaces it
+	lastScanMu             sync.Mutex
+	lastScan               ScanStatus
+	observedMu             sync.Mutex
+	observed               map[string]bool  // paths logged since the last scan
+	coalescer              *coalescer       // nil when every event is logged
+	logged                 map[string]int64 // keys of the events logged at or after the cursor, to their time
 }
 
 func New(
 	appLogger *slog.Logger,
 	cfg *config.Config,
-	osqueryManager osquerymanager.OSQueryManager,
+	source EventSource,
 	logStore mongolog.LogStore,
+	reporter reporter.Reporter,
+	events eventbus.Bus,
 ) FileChangesTracker {
+	watches := make([]watch, 0, len(cfg.Directories))
+	for _, w := range cfg.Directories {
+		watches = append(watches, newWatch(w))
+	}
+
+	var c *coalescer
+	if cfg.CoalesceWindow > 0 {
+		c = newCoalescer(int64(cfg.CoalesceWindow), cfg.CoalesceRawEvents)
+	}
+
 	return &fileChangesTracker{
+		coalescer:              c,
+		watches:                watches,
 		appLogger:              appLogger,
 		config:                 cfg,
-		osqueryManager:         osqueryManager,
+		source:                 source,
 		logStore:               logStore,
+		reporter:               reporter,
+		events:                 events,
 		lastProcessedTimestamp: time.Now().Unix(),
+		logged:                 make(map[string]int64),
+		scanCtx:                context.Background(),
 	}
 }
 
 func (f *fileChangesTracker) Start(ctx context.Context) error {
+	err := f.restoreWatches()
+	if err != nil {
+		return fmt.Errorf("error restoring watches: %w", err)
+	}
+	for _, w := range f.currentWatches() {
+		f.watchSource(w)
+	}
+
+	err = f.resumeCheckpoint()
+	if err != nil {
+		return fmt.Errorf("error resuming from checkpoint: %w", err)
+	}
+
+	err = f.restoreBaseline(ctx)
+	if err != nil {
+		return err
+	}
+	f.scanCtx = ctx
+
 	go f.timerThread(ctx)
+	if f.config.BaselineScanInterval > 0 {
+		go f.scanThread(ctx)
+	}
 
 	return nil
 }
 
 func (f *fileChangesTracker) Stop(ctx context.Context) error {
-	f.osqueryManager.Close()
+	return f.source.Close()
+}
+
+// checkpointPath returns where the cursor is persisted, empty when the
+// tracker has no data directory and only keeps the cursor in memory.
+func (f *fileChangesTracker) checkpointPath() string {
+	if f.config.DataDir == "" {
+		return ""
+	}
+
+	return filepath.Join(f.config.DataDir, checkpointFileName)
+}
+
+// resumeCheckpoint moves the cursor back to the last saved checkpoint so
+// events that happened while the app was down are picked up, but never
+// further back than the configured catch-up window.
+func (f *fileChangesTracker) resumeCheckpoint() error {
+	path := f.checkpointPath()
+	if path == "" {
+		return nil
+	}
+
+	saved, ok, err := loadCheckpoint(path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	now := time.Now().Unix()
+	cursor := saved
+	if f.config.MaxCatchupWindow > 0 {
+		earliest := now - int64(f.config.MaxCatchupWindow)
+		if cursor < earliest {
+			f.appLogger.Warn("checkpoint-outside-catchup-window",
+				slog.Int64("checkpoint", saved),
+				slog.Int64("resuming_from", earliest),
+			)
+			cursor = earliest
+		}
+	}
+	if cursor > now {
+		cursor = now
+	}
+
+	f.lastProcessedTimestamp = cursor
+	f.appLogger.Info("resumed-from-checkpoint", slog.Int64("last_processed_timestamp", cursor))
+
 	return nil
 }
 
+// commitCheckpoint persists the cursor, failures are logged and retried on
+// the next check since the in-memory cursor is still correct.
+func (f *fileChangesTracker) commitCheckpoint() {
+	path := f.checkpointPath()
+	if path == "" {
+		return
+	}
+
+	err := saveCheckpoint(path, f.lastProcessedTimestamp)
+	if err != nil {
+		f.appLogger.Error("error-saving-checkpoint", slog.String("error", err.Error()))
+	}
+}
+
 func (f *fileChangesTracker) timerThread(ctx context.Context) {
 	checkFrequency := time.Duration(f.config.CheckFrequency) * time.Second
 	for {
@@ -80,35 +206,174 @@ func (f *fileChangesTracker) timerThread(ctx context.Context) {
 }
 
 func (f *fileChangesTracker) checkFileChanges(ctx context.Context) error {
-	query := fmt.Sprintf("SELECT * FROM file_events WHERE target_path LIKE '%s%%'  AND time > %d;", f.config.Directory, f.lastProcessedTimestamp)
-	res, err := f.osqueryManager.Query(query)
-	if err != nil {
-		if errors.Is(err, osquerymanager.ErrNoChangesFound) {
-			return nil
+	since := f.lastProcessedTimestamp
+	next := since
+	changes := make([]map[string]string, 0)
+	seen := make(map[string]bool)
+
+	watches := f.currentWatches()
+	var failed int
+	var queryErr error
+	for _, w := range watches {
+		// events in the second of since are read again as events are only
+		// second-resolution, the ones already logged are left out here
+		rows, err := f.source.Query(w, since)
+		if err != nil {
+			// a watch that keeps failing, like a root that was deleted, must
+			// not keep the other watches from being logged
+			f.appLogger.Error("error-querying-file-changes", slog.String("watch", w.Path), slog.String("error", err.Error()))
+			failed++
+			queryErr = err
+			continue
+		}
+
+		for _, row := range rows {
+			if !w.matches(row) {
+				continue
+			}
+			key := eventKey(row)
+			if _, ok := f.logged[key]; ok || seen[key] {
+				continue
+			}
+			seen[key] = true
+			row["watch"] = w.Path
+
+			f.appLogger.Debug("new change detected", slog.String("target_path", row["target_path"]), slog.String("watch", w.Path))
+			changes = append(changes, row)
+
+			changeTime, err := strconv.ParseInt(row["time"], 10, 64)
+			if err != nil {
+				continue
+			}
+			if changeTime > next {
+				next = changeTime
+			}
 		}
-		return fmt.Errorf("error querying file changes: %w", err)
 	}
 
-	for _, row := range res {
-		f.appLogger.Debug("new change detected", slog.String("target_path", row["target_path"]))
+	if failed > 0 && failed == len(watches) {
+		// the source itself is down, the cursor stays where it was
+		return fmt.Errorf("error querying file changes: %w", queryErr)
+	}
 
-		err := f.logStore.Write(ctx, row)
+	// a failed batch leaves the cursor where it was, the changes are read
+	// again on the next check
+	if f.coalescer != nil {
+		err := f.logCoalesced(ctx, changes)
 		if err != nil {
-			return fmt.Errorf("error writing log: %w", err)
+			return err
 		}
-
-		changeTime, err := strconv.ParseInt(row["time"], 10, 64)
+		next = f.coalescer.hold(next)
+	} else {
+		err := f.logChanges(ctx, changes)
 		if err != nil {
-			continue
+			return err
+		}
+		f.markLogged(changes)
+	}
+	f.observe(changes)
+
+	// only move the cursor once the batch was written. The events of a
+	// watch that failed while others were read are not read again, a watch
+	// that keeps failing would otherwise hold the cursor back for all.
+	if next != since {
+		f.lastProcessedTimestamp = next
+		f.commitCheckpoint()
+	}
+	f.forgetLogged(f.lastProcessedTimestamp)
+
+	return nil
+}
+
+// eventKey identifies an event however many times it is read, the watch is
+// left out so overlapping watches don't log it twice
+func eventKey(row map[string]string) string {
+	return row["eid"] + "\x00" + row["target_path"] + "\x00" + row["time"] + "\x00" + row["action"]
+}
+
+// markLogged remembers the logged rows until the cursor passes them, so
+// the re-read of the cursor's second doesn't write them again
+func (f *fileChangesTracker) markLogged(rows []map[string]string) {
+	for _, row := range rows {
+		eventTime, _ := strconv.ParseInt(row["time"], 10, 64)
+		f.logged[eventKey(row)] = eventTime
+	}
+}
+
+// forgetLogged drops the keys of events before cursor, they are not read
+// again
+func (f *fileChangesTracker) forgetLogged(cursor int64) {
+	for key, eventTime := range f.logged {
+		if eventTime < cursor {
+			delete(f.logged, key)
 		}
-		if changeTime > f.lastProcessedTimestamp {
-			f.lastProcessedTimestamp = changeTime
+	}
+}
+
+// logChanges hashes the changed files and writes the changes in one batch,
+// then publishes and reports the ones the store did not have yet. Re-read
+// events are dropped before this, so an idle check hashes nothing.
+func (f *fileChangesTracker) logChanges(ctx context.Context, changes []map[string]string) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	f.hashChanges(ctx, changes)
+
+	entries, err := f.logStore.WriteBatch(ctx, changes)
+	if err != nil {
+		return fmt.Errorf("error writing logs: %w", err)
+	}
+
+	written := make([]map[string]string, 0, len(entries))
+	for _, entry := range entries {
+		f.events.Publish(eventbus.Event{Topic: eventbus.TopicFileChange, ID: entry.Cursor(), Data: entry})
+		written = append(written, entry.Details)
+	}
+	f.report(written)
+
+	return nil
+}
+
+// logCoalesced adds the changes to their groups and logs the groups whose
+// window has passed, a failed batch keeps them for the next check
+func (f *fileChangesTracker) logCoalesced(ctx context.Context, changes []map[string]string) error {
+	for _, row := range changes {
+		f.coalescer.add(row)
+	}
+
+	groups := f.coalescer.ready(time.Now().Unix())
+	rows := make([]map[string]string, 0, len(groups))
+	for _, g := range groups {
+		rows = append(rows, g.row(f.coalescer.rawEvents))
+	}
+
+	err := f.logChanges(ctx, rows)
+	if err != nil {
+		return err
+	}
+	f.coalescer.commit(groups)
+	for _, g := range groups {
+		for key, eventTime := range g.keys {
+			f.logged[key] = eventTime
 		}
 	}
 
 	return nil
 }
 
+// report hands rows that made it into the log store to the reporter
+func (f *fileChangesTracker) report(rows []map[string]string) {
+	if len(rows) == 0 {
+		return
+	}
+
+	err := f.reporter.Enqueue(rows)
+	if err != nil {
+		f.appLogger.Error("error-enqueueing-report", slog.String("error", err.Error()))
+	}
+}
+
 func (f *fileChangesTracker) IsTimerThreadAlive() bool {
 	checkFrequency := time.Duration(f.config.CheckFrequency) * time.Second
 	buffer := 30 * time.Second
@@ -120,9 +385,26 @@ func (f *fileChangesTracker) IsTimerThreadAlive() bool {
 	return time.Since(f.timerLastHeartbeat) < deadline
 }
 
-func (f *fileChangesTracker) GetLogs(ctx context.Context, limit, offset int64) ([]mongolog.LogEntry, error) {
-	res, err := f.logStore.ReadLogsPaginated(ctx, limit, offset)
+func (f *fileChangesTracker) GetLogs(ctx context.Context, query mongolog.LogQuery) (mongolog.LogPage, error) {
+	res, err := f.logStore.ReadLogsPaginated(ctx, query)
 	if err != nil {
+		if errors.Is(err, mongolog.ErrInvalidCursor) {
+			return mongolog.LogPage{}, err
+		}
+		f.appLogger.Error("error-loading-from-logs-db", slog.String("error", err.Error()))
+		return mongolog.LogPage{}, fmt.Errorf("error loading from db: %w", err)
+	}
+
+	return res, nil
+}
+
+// GetLogsAfter returns the logs written after query.Cursor, oldest first
+func (f *fileChangesTracker) GetLogsAfter(ctx context.Context, query mongolog.LogQuery) ([]mongolog.LogEntry, error) {
+	res, err := f.logStore.ReadLogsAfter(ctx, query)
+	if err != nil {
+		if errors.Is(err, mongolog.ErrInvalidCursor) {
+			return nil, err
+		}
 		f.appLogger.Error("error-loading-from-logs-db", slog.String("error", err.Error()))
 		return nil, fmt.Errorf("error loading from db: %w", err)
 	}
@@ -2,18 +2,20 @@ package filechangestracker
 
 import (
 	"context"
+	"errors"
 	"log/slog"
 	"strconv"
 	"testing"
 	"time"
 
 	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
 	"github.com/danielboakye/filechangestracker/internal/mongolog"
 	mongologmock "github.com/danielboakye/filechangestracker/mocks/mongolog"
 	osquerymanagermock "github.com/danielboakye/filechangestracker/mocks/osquerymanager"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
 	"github.com/danielboakye/filechangestracker/pkg/osquerymanager"
 	"github.com/golang/mock/gomock"
-	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -23,20 +25,25 @@ import (
 // go test -v -cover -run TestCheckFileChanges ./pkg/filechangestracker
 func TestCheckFileChanges(t *testing.T) {
 	assert := assert.New(t)
+	require := require.New(t)
 
 	mockCtrl := gomock.NewController(t)
 	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
-	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
 
 	ctx := context.Background()
 
-	cfg := &config.Config{}
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: "test", Recursive: true}},
+	}
 	appLogger := slog.Default()
 
-	tracker := New(appLogger, cfg, mockOSQueryManager, mockMongolog)
+	events := eventbus.New(appLogger)
+	sub := events.Subscribe(nil)
+	tracker := New(appLogger, cfg, NewOSQuerySource(mockOSQueryManager), mongolog.NewMemoryLogStore(0), mockReporter, events)
 	it := tracker.(*fileChangesTracker)
 
-	mockMongolog.EXPECT().Write(gomock.Any(), gomock.Any()).Return(nil).Times(1)
+	mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil).Times(1)
 
 	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
 	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
@@ -46,23 +53,18 @@ func TestCheckFileChanges(t *testing.T) {
 		},
 	}, nil).AnyTimes()
 
-	mockMongolog.EXPECT().ReadLogsPaginated(gomock.Any(), gomock.Any(), gomock.Any()).Return([]mongolog.LogEntry{
-		{
-			ID: uuid.NewString(),
-			Details: map[string]string{
-				"target_path": "test/test.txt",
-				"time":        timeStr,
-			},
-		},
-	}, nil).Times(1)
-
 	err := it.checkFileChanges(ctx)
 	assert.Nil(err)
 
-	res, err := tracker.GetLogs(ctx, 1, 0)
+	res, err := tracker.GetLogs(ctx, mongolog.LogQuery{Limit: 1})
 	assert.Nil(err)
-	assert.NotNil(res)
-	assert.Len(res, 1)
+	require.Len(res.Items, 1)
+	assert.Equal("test/test.txt", res.Items[0].Details["target_path"])
+
+	require.Len(sub.Events(), 1)
+	event := <-sub.Events()
+	assert.Equal(eventbus.TopicFileChange, event.Topic)
+	assert.Equal(res.Items[0].ID, event.Data.(mongolog.LogEntry).ID)
 }
 
 // go test -v -cover -run TestHealthCheck ./pkg/filechangestracker
@@ -73,6 +75,7 @@ func TestHealthCheck(t *testing.T) {
 	mockCtrl := gomock.NewController(t)
 	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
 	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -80,9 +83,10 @@ func TestHealthCheck(t *testing.T) {
 	cfg := &config.Config{}
 	appLogger := slog.Default()
 
-	tracker := New(appLogger, cfg, mockOSQueryManager, mockMongolog)
+	tracker := New(appLogger, cfg, NewOSQuerySource(mockOSQueryManager), mockMongolog, mockReporter, eventbus.New(slog.Default()))
 
 	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return(nil, osquerymanager.ErrNoChangesFound).AnyTimes()
+	mockMongolog.EXPECT().LoadBaseline(gomock.Any()).Return(mongolog.Baseline{}, false, nil).Times(1)
 
 	err := tracker.Start(ctx)
 	require.NoError(err)
@@ -92,3 +96,253 @@ func TestHealthCheck(t *testing.T) {
 	isAlive := tracker.IsTimerThreadAlive()
 	assert.True(isAlive)
 }
+
+// go test -v -cover -run TestCheckFileChanges_SavesCheckpoint ./pkg/filechangestracker
+func TestCheckFileChanges_SavesCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		DataDir:     t.TempDir(),
+		Directories: []config.WatchConfig{{Path: "test", Recursive: true}},
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mockMongolog, mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	changeTime := time.Now().Unix() + 5
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
+		{
+			"target_path": "test/test.txt",
+			"time":        strconv.FormatInt(changeTime, 10),
+		},
+	}, nil).Times(1)
+	mockMongolog.EXPECT().WriteBatch(gomock.Any(), gomock.Len(1)).Return([]mongolog.LogEntry{{ID: "1"}}, nil).Times(1)
+	mockReporter.EXPECT().Enqueue(gomock.Any()).Return(nil).Times(1)
+
+	err := it.checkFileChanges(context.Background())
+	require.NoError(err)
+
+	saved, ok, err := loadCheckpoint(it.checkpointPath())
+	require.NoError(err)
+	assert.True(ok)
+	assert.Equal(changeTime, saved)
+}
+
+// go test -v -cover -run TestCheckFileChanges_FailedBatch ./pkg/filechangestracker
+func TestCheckFileChanges_FailedBatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		DataDir: t.TempDir(),
+		Directories: []config.WatchConfig{
+			{Path: "one", Recursive: true},
+			{Path: "two", Recursive: true},
+		},
+	}
+	events := eventbus.New(slog.Default())
+	sub := events.Subscribe(nil)
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mockMongolog, mockReporter, events)
+	it := tracker.(*fileChangesTracker)
+	since := it.lastProcessedTimestamp
+
+	changeTime := strconv.FormatInt(time.Now().Unix()+5, 10)
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
+		{"target_path": "one/a.txt", "time": changeTime},
+	}, nil).Times(1)
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
+		{"target_path": "two/b.txt", "time": changeTime},
+	}, nil).Times(1)
+	// both watches go in one batch, nothing is reported or published when it fails
+	mockMongolog.EXPECT().WriteBatch(gomock.Any(), gomock.Len(2)).Return(nil, errors.New("connection lost")).Times(1)
+
+	err := it.checkFileChanges(context.Background())
+	require.Error(err)
+
+	assert.Equal(since, it.lastProcessedTimestamp)
+	_, ok, err := loadCheckpoint(it.checkpointPath())
+	require.NoError(err)
+	assert.False(ok)
+	assert.Len(sub.Events(), 0)
+}
+
+// go test -v -cover -run TestCheckFileChanges_FailedWatch ./pkg/filechangestracker
+func TestCheckFileChanges_FailedWatch(t *testing.T) {
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{
+			{Path: "one", Recursive: true},
+			{Path: "two", Recursive: true},
+		},
+	}
+	logStore := mongolog.NewMemoryLogStore(0)
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), logStore, mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+	since := it.lastProcessedTimestamp
+
+	changeTime := time.Now().Unix() + 5
+	gomock.InOrder(
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return(nil, errors.New("no such directory")),
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
+			{"target_path": "two/b.txt", "time": strconv.FormatInt(changeTime, 10)},
+		}, nil),
+		// every watch failing leaves the cursor as it was
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return(nil, errors.New("connection lost")).Times(2),
+	)
+	mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil).Times(1)
+
+	// the failing watch doesn't keep the other from being logged
+	require.NoError(it.checkFileChanges(context.Background()))
+	require.Equal(changeTime, it.lastProcessedTimestamp)
+
+	it.lastProcessedTimestamp = since
+	require.Error(it.checkFileChanges(context.Background()))
+	require.Equal(since, it.lastProcessedTimestamp)
+
+	page, err := logStore.ReadLogsPaginated(context.Background(), mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	require.Equal(int64(1), page.Total)
+}
+
+// go test -v -cover -run TestCheckFileChanges_SameSecond ./pkg/filechangestracker
+func TestCheckFileChanges_SameSecond(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: "test", Recursive: true}},
+	}
+	logStore := mongolog.NewMemoryLogStore(0)
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), logStore, mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	changeTime := strconv.FormatInt(time.Now().Unix()+5, 10)
+	first := map[string]string{"eid": "1", "target_path": "test/a.txt", "action": "CREATED", "time": changeTime}
+	second := map[string]string{"eid": "2", "target_path": "test/b.txt", "action": "CREATED", "time": changeTime}
+
+	// the second event happens in the same second after the first check,
+	// the next check reads the first one again
+	gomock.InOrder(
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{first}, nil),
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{copyDetails(first), second}, nil),
+	)
+	gomock.InOrder(
+		mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil),
+		mockReporter.EXPECT().Enqueue([]map[string]string{second}).Return(nil),
+	)
+
+	require.NoError(it.checkFileChanges(context.Background()))
+	require.NoError(it.checkFileChanges(context.Background()))
+
+	page, err := logStore.ReadLogsPaginated(context.Background(), mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	assert.Equal(int64(2), page.Total)
+}
+
+// go test -v -cover -run TestCheckFileChanges_Reread ./pkg/filechangestracker
+func TestCheckFileChanges_Reread(t *testing.T) {
+	require := require.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		Directories: []config.WatchConfig{{Path: "test", Recursive: true}},
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mockMongolog, mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	changeTime := strconv.FormatInt(time.Now().Unix()+5, 10)
+	first := map[string]string{"eid": "1", "target_path": "test/a.txt", "action": "CREATED", "time": changeTime}
+	second := map[string]string{"eid": "2", "target_path": "test/b.txt", "action": "CREATED", "time": changeTime}
+
+	// the idle check in between reads the first event again and writes nothing
+	gomock.InOrder(
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{first}, nil),
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{copyDetails(first)}, nil),
+		mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{copyDetails(first), second}, nil),
+	)
+	gomock.InOrder(
+		mockMongolog.EXPECT().WriteBatch(gomock.Any(), []map[string]string{first}).Return([]mongolog.LogEntry{{ID: "1", Details: first}}, nil),
+		mockMongolog.EXPECT().WriteBatch(gomock.Any(), []map[string]string{second}).Return([]mongolog.LogEntry{{ID: "2", Details: second}}, nil),
+	)
+	mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil).Times(2)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(it.checkFileChanges(context.Background()))
+	}
+	require.Len(it.logged, 2)
+}
+
+// go test -v -cover -run TestResumeCheckpoint ./pkg/filechangestracker
+func TestResumeCheckpoint(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name             string
+		saved            int64
+		maxCatchupWindow int
+		expected         int64
+	}{
+		{"Resumes from recent checkpoint", now - 60, 3600, now - 60},
+		{"Caps old checkpoint to catch-up window", now - 7200, 3600, now - 3600},
+		{"No catch-up window resumes from checkpoint", now - 7200, 0, now - 7200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				DataDir:          t.TempDir(),
+				MaxCatchupWindow: tt.maxCatchupWindow,
+			}
+			tracker := New(slog.Default(), cfg, nil, nil, nil, nil)
+			it := tracker.(*fileChangesTracker)
+
+			require.NoError(t, saveCheckpoint(it.checkpointPath(), tt.saved))
+			require.NoError(t, it.resumeCheckpoint())
+
+			// allow for the clock ticking over while the test runs
+			assert.InDelta(t, tt.expected, it.lastProcessedTimestamp, 1)
+		})
+	}
+}
+
+// go test -v -cover -run TestOSQuerySource_EscapesPath ./pkg/filechangestracker
+func TestOSQuerySource_EscapesPath(t *testing.T) {
+	assert := assert.New(t)
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+
+	source := NewOSQuerySource(mockOSQueryManager)
+
+	w := newWatch(config.WatchConfig{Path: "/Users/me/it's 100%_done", Actions: []string{"CREATED"}})
+	mockOSQueryManager.EXPECT().
+		Query(`SELECT * FROM file_events WHERE target_path LIKE '/Users/me/it''s 100\%\_done/%' ESCAPE '\' AND time >= 42 AND action IN ('CREATED');`).
+		Return(nil, osquerymanager.ErrNoChangesFound).
+		Times(1)
+
+	rows, err := source.Query(w, 42)
+	assert.NoError(err)
+	assert.Empty(rows)
+}
@@ -0,0 +1,287 @@
+package filechangestracker
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fsnotifyPendingLimit caps the events kept for the tracker, the oldest are
+// dropped when the tracker can't keep up or nothing queries them
+const fsnotifyPendingLimit = 10000
+
+// fsnotifySource watches the directories with inotify on Linux, kqueue on
+// macOS and BSD or ReadDirectoryChangesW on Windows and keeps their events
+// until the tracker queries them. Unlike osquery it only sees what happens
+// while the app runs.
+//
+// kqueue holds a file descriptor open per watched file and directory, so a
+// recursive watch of a large tree on macOS runs into the open files limit
+// (EMFILE) unless it is raised with ulimit -n. inotify has a limit of its
+// own in fs.inotify.max_user_watches.
+type fsnotifySource struct {
+	appLogger *slog.Logger
+	watcher   *fsnotify.Watcher
+	eidPrefix string // keeps event ids unique across restarts
+
+	mu      sync.Mutex
+	roots   map[string]bool // watched roots, true when recursive
+	pending []map[string]string
+	seq     int64
+}
+
+func NewFSNotifySource(appLogger *slog.Logger) (EventSource, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("error creating fsnotify watcher: %w", err)
+	}
+
+	s := &fsnotifySource{
+		appLogger: appLogger,
+		watcher:   watcher,
+		eidPrefix: strconv.FormatInt(time.Now().UnixNano(), 36),
+		roots:     make(map[string]bool),
+	}
+	go s.run()
+
+	return s, nil
+}
+
+// Query returns the events recorded below w. A root that failed to be
+// watched, like one that did not exist yet, is watched again first.
+func (s *fsnotifySource) Query(w watch, since int64) ([]map[string]string, error) {
+	err := s.Watch(w)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rows []map[string]string
+	kept := s.pending[:0]
+	for _, row := range s.pending {
+		eventTime, _ := strconv.ParseInt(row["time"], 10, 64)
+		if eventTime < since {
+			continue
+		}
+		kept = append(kept, row)
+
+		if strings.HasPrefix(row["target_path"], w.prefix()) {
+			// the tracker adds to the rows it gets
+			rows = append(rows, copyDetails(row))
+		}
+	}
+	s.pending = kept
+
+	return rows, nil
+}
+
+func (s *fsnotifySource) Close() error {
+	return s.watcher.Close()
+}
+
+// Watch watches the root of w, and its subdirectories when recursive.
+// Events from before it are not known.
+func (s *fsnotifySource) Watch(w watch) error {
+	s.mu.Lock()
+	recursive, ok := s.roots[w.root]
+	s.mu.Unlock()
+	if ok && (recursive || !w.Recursive) {
+		return nil
+	}
+
+	err := s.addDir(w.root, w.Recursive, false)
+	if err != nil {
+		return fmt.Errorf("error watching %s: %w", w.root, err)
+	}
+
+	s.mu.Lock()
+	s.roots[w.root] = w.Recursive
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Unwatch stops watching the root of w and the subdirectories no other root
+// still covers
+func (s *fsnotifySource) Unwatch(w watch) error {
+	s.mu.Lock()
+	delete(s.roots, w.root)
+	var unwatched []string
+	for _, path := range s.watcher.WatchList() {
+		if within(path, w.root) && !s.covered(path) {
+			unwatched = append(unwatched, path)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, path := range unwatched {
+		err := s.watcher.Remove(path)
+		if err != nil && !errors.Is(err, fsnotify.ErrNonExistentWatch) {
+			return fmt.Errorf("error unwatching %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// covered reports whether a root still watches dir. The caller must hold mu.
+func (s *fsnotifySource) covered(dir string) bool {
+	for root, recursive := range s.roots {
+		if root == dir || (recursive && within(dir, root)) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether path is root or below it
+func within(path, root string) bool {
+	return path == root || root == "/" || strings.HasPrefix(path, root+"/")
+}
+
+// addDir watches dir, and its subdirectories when recursive. Files found
+// in them are reported as created when report is set, for directories that
+// were created just before they were watched. Subdirectories the app may
+// not read are skipped.
+func (s *fsnotifySource) addDir(dir string, recursive, report bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path != dir && os.IsNotExist(err) {
+				return nil // removed while walking
+			}
+			if path != dir && errors.Is(err, fs.ErrPermission) {
+				s.appLogger.Warn("skipped-unreadable-directory", slog.String("directory", path))
+				return skipEntry(d)
+			}
+			return err
+		}
+
+		if !d.IsDir() {
+			if report {
+				s.add(path, "CREATED")
+			}
+			return nil
+		}
+		if path != dir && !recursive {
+			return filepath.SkipDir
+		}
+
+		err = s.watcher.Add(path)
+		if path != dir && errors.Is(err, fs.ErrPermission) {
+			s.appLogger.Warn("skipped-unreadable-directory", slog.String("directory", path))
+			return filepath.SkipDir
+		}
+		return err
+	})
+}
+
+// skipEntry skips the rest of a directory that failed to be read, or
+// nothing when it was a file that failed
+func skipEntry(d fs.DirEntry) error {
+	if d != nil && d.IsDir() {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
+func (s *fsnotifySource) run() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			s.handle(event)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.appLogger.Error("fsnotify-error", slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (s *fsnotifySource) handle(event fsnotify.Event) {
+	switch {
+	case event.Has(fsnotify.Create):
+		s.add(event.Name, "CREATED")
+
+		info, err := os.Lstat(event.Name)
+		if err == nil && info.IsDir() && s.isRecursive(event.Name) {
+			err = s.addDir(event.Name, true, true)
+			if err != nil {
+				s.appLogger.Error("error-watching-directory", slog.String("directory", event.Name), slog.String("error", err.Error()))
+			}
+		}
+	case event.Has(fsnotify.Write):
+		s.add(event.Name, "UPDATED")
+	case event.Has(fsnotify.Remove):
+		s.add(event.Name, "DELETED")
+	case event.Has(fsnotify.Rename):
+		// the new name arrives as a create
+		s.add(event.Name, "MOVED_FROM")
+	case event.Has(fsnotify.Chmod):
+		s.add(event.Name, "ATTRIBUTES_MODIFIED")
+	}
+}
+
+// isRecursive reports whether path is below a recursive root
+func (s *fsnotifySource) isRecursive(path string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for root, recursive := range s.roots {
+		if recursive && (root == "/" || strings.HasPrefix(path, root+"/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// add records an event as a file_events row
+func (s *fsnotifySource) add(path, action string) {
+	row := map[string]string{
+		"target_path": path,
+		"action":      action,
+		"time":        strconv.FormatInt(time.Now().Unix(), 10),
+		"category":    "fsnotify",
+	}
+	info, err := os.Lstat(path)
+	if err == nil {
+		row["size"] = strconv.FormatInt(info.Size(), 10)
+		row["mode"] = fmt.Sprintf("%04o", info.Mode().Perm())
+		row["mtime"] = strconv.FormatInt(info.ModTime().Unix(), 10)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	row["eid"] = s.eidPrefix + "-" + strconv.FormatInt(s.seq, 10)
+
+	if len(s.pending) >= fsnotifyPendingLimit {
+		s.appLogger.Warn("dropped-file-event", slog.String("target_path", s.pending[0]["target_path"]))
+		s.pending[0] = nil
+		s.pending = s.pending[1:]
+	}
+	s.pending = append(s.pending, row)
+}
+
+func copyDetails(row map[string]string) map[string]string {
+	res := make(map[string]string, len(row))
+	for k, v := range row {
+		res[k] = v
+	}
+	return res
+}
@@ -0,0 +1,126 @@
+package filechangestracker
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestFSNotifySource ./internal/filechangestracker
+func TestFSNotifySource(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewFSNotifySource(slog.Default())
+	require.NoError(t, err)
+	defer source.Close()
+
+	w := newWatch(config.WatchConfig{Path: dir, Recursive: true})
+	since := time.Now().Unix()
+
+	// changes made before the first query are recorded
+	require.NoError(t, source.Watch(w))
+
+	file := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("a"), 0o644))
+	// files in a new directory are found even when they beat its watch
+	nested := filepath.Join(dir, "sub", "b.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(nested), 0o755))
+	require.NoError(t, os.WriteFile(nested, []byte("b"), 0o644))
+
+	seen := make(map[string]bool)
+	require.Eventually(t, func() bool {
+		rows, err := source.Query(w, since)
+		require.NoError(t, err)
+		for _, row := range rows {
+			assert.NotEmpty(t, row["eid"])
+			assert.NotEmpty(t, row["time"])
+			seen[row["target_path"]+" "+row["action"]] = true
+		}
+		return seen[file+" CREATED"] && seen[nested+" CREATED"]
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// events before since are dropped
+	rows, err := source.Query(w, time.Now().Unix()+1)
+	require.NoError(t, err)
+	assert.Empty(t, rows)
+
+	// a watch only gets its own events
+	other := newWatch(config.WatchConfig{Path: filepath.Join(dir, "sub"), Recursive: true})
+	require.NoError(t, os.Remove(file))
+	require.Eventually(t, func() bool {
+		rows, err := source.Query(w, since)
+		require.NoError(t, err)
+		for _, row := range rows {
+			if row["target_path"] == file && row["action"] == "DELETED" {
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	rows, err = source.Query(other, since)
+	require.NoError(t, err)
+	for _, row := range rows {
+		assert.NotEqual(t, file, row["target_path"])
+	}
+}
+
+// go test -v -cover -run TestFSNotifySource_UnreadableDirectory ./internal/filechangestracker
+func TestFSNotifySource_UnreadableDirectory(t *testing.T) {
+	dir := t.TempDir()
+	source, err := NewFSNotifySource(slog.Default())
+	require.NoError(t, err)
+	defer source.Close()
+
+	// a missing root fails its own watch only
+	_, err = source.Query(newWatch(config.WatchConfig{Path: filepath.Join(dir, "missing"), Recursive: true}), time.Now().Unix())
+	assert.Error(t, err)
+
+	if os.Geteuid() == 0 {
+		t.Skip("root reads every directory")
+	}
+	locked := filepath.Join(dir, "locked")
+	require.NoError(t, os.Mkdir(locked, 0o000))
+	defer os.Chmod(locked, 0o755)
+
+	_, err = source.Query(newWatch(config.WatchConfig{Path: dir, Recursive: true}), time.Now().Unix())
+	assert.NoError(t, err)
+}
+
+// go test -v -cover -run TestFSNotifySource_Unwatch ./internal/filechangestracker
+func TestFSNotifySource_Unwatch(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0o755))
+
+	source, err := NewFSNotifySource(slog.Default())
+	require.NoError(t, err)
+	defer source.Close()
+	s := source.(*fsnotifySource)
+	watcher := s.watcher
+
+	w := newWatch(config.WatchConfig{Path: dir, Recursive: true})
+	nested := newWatch(config.WatchConfig{Path: sub})
+	require.NoError(t, source.Watch(w))
+	require.NoError(t, source.Watch(nested))
+	assert.ElementsMatch(t, []string{dir, sub}, watcher.WatchList())
+
+	// a directory another root still covers stays watched
+	require.NoError(t, source.Unwatch(nested))
+	assert.ElementsMatch(t, []string{dir, sub}, watcher.WatchList())
+
+	require.NoError(t, source.Unwatch(w))
+	assert.Empty(t, watcher.WatchList())
+
+	// no events are recorded for it anymore
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0o644))
+	time.Sleep(100 * time.Millisecond)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	assert.Empty(t, s.pending)
+}
@@ -0,0 +1,137 @@
+package filechangestracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/danielboakye/filechangestracker/pkg/fuzzyhash"
+)
+
+var (
+	errNotRegularFile = errors.New("not a regular file")
+	errFileTooLarge   = errors.New("file is larger than hashing.max_size")
+)
+
+// hashActions are the actions after which the file has content to hash
+var hashActions = map[string]bool{
+	"CREATED":  true,
+	"UPDATED":  true,
+	"MOVED_TO": true,
+}
+
+// hashChanges fills in the sha256 the event source left out, and the fuzzy
+// hash when enabled, of created and updated files. Files are hashed as they
+// are by now, by a pool of workers. Files over the size cap or gone since
+// the event are left without.
+func (f *fileChangesTracker) hashChanges(ctx context.Context, changes []map[string]string) {
+	if !f.config.HashingEnabled {
+		return
+	}
+
+	var pending []map[string]string
+	for _, row := range changes {
+		if !hashActions[row["action"]] {
+			continue
+		}
+		if row["sha256"] == "" || (f.config.HashingFuzzy && row["fuzzy_hash"] == "") {
+			pending = append(pending, row)
+		}
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	workers := f.config.HashingWorkers
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	rows := make(chan map[string]string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rows {
+				f.hashRow(row)
+			}
+		}()
+	}
+
+feed:
+	for _, row := range pending {
+		select {
+		case rows <- row:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(rows)
+	wg.Wait()
+}
+
+// hashRow adds the hashes to row, a sha256 from the event source is kept
+func (f *fileChangesTracker) hashRow(row map[string]string) {
+	sha, fuzzy, err := hashFile(row["target_path"], f.config.HashingMaxSize, f.config.HashingFuzzy)
+	if err != nil {
+		f.appLogger.Debug("file-not-hashed", slog.String("target_path", row["target_path"]), slog.String("error", err.Error()))
+		return
+	}
+
+	if row["sha256"] == "" {
+		row["sha256"] = sha
+	}
+	if fuzzy != "" {
+		row["fuzzy_hash"] = fuzzy
+	}
+}
+
+// hashFile returns the sha256 of the file, and its ssdeep digest when fuzzy
+// is set, reading it once. maxSize 0 hashes files of any size.
+func hashFile(path string, maxSize int64, fuzzy bool) (sha string, fuzzyHash string, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", "", errNotRegularFile
+	}
+	if maxSize > 0 && info.Size() > maxSize {
+		return "", "", errFileTooLarge
+	}
+
+	hash := sha256.New()
+	var w io.Writer = hash
+	var fuzzyHasher *fuzzyhash.Hasher
+	if fuzzy {
+		fuzzyHasher = fuzzyhash.New()
+		w = io.MultiWriter(hash, fuzzyHasher)
+	}
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		return "", "", err
+	}
+
+	sha = hex.EncodeToString(hash.Sum(nil))
+	if fuzzyHasher != nil {
+		fuzzyHash = fuzzyHasher.Sum()
+	}
+
+	return sha, fuzzyHash, nil
+}
@@ -0,0 +1,124 @@
+package filechangestracker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	mongologmock "github.com/danielboakye/filechangestracker/mocks/mongolog"
+	osquerymanagermock "github.com/danielboakye/filechangestracker/mocks/osquerymanager"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestCheckFileChanges_HashesFiles ./pkg/filechangestracker
+func TestCheckFileChanges_HashesFiles(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	created := filepath.Join(dir, "created.txt")
+	require.NoError(os.WriteFile(created, []byte("hello"), 0o644))
+	updated := filepath.Join(dir, "updated.txt")
+	require.NoError(os.WriteFile(updated, []byte("world"), 0o644))
+	large := filepath.Join(dir, "large.bin")
+	require.NoError(os.WriteFile(large, []byte(strings.Repeat("x", 100)), 0o644))
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+	mockReporter.EXPECT().Enqueue(gomock.Any()).Return(nil).Times(1)
+
+	cfg := &config.Config{
+		Directories:    []config.WatchConfig{{Path: dir, Recursive: true}},
+		HashingEnabled: true,
+		HashingMaxSize: 50,
+		HashingWorkers: 2,
+		HashingFuzzy:   true,
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mongolog.NewMemoryLogStore(0), mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	osquerySHA := strings.Repeat("ab", 32)
+	timeStr := strconv.FormatInt(time.Now().Unix(), 10)
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).Return([]map[string]string{
+		{"eid": "1", "target_path": created, "action": "CREATED", "time": timeStr},
+		{"eid": "2", "target_path": updated, "action": "UPDATED", "time": timeStr, "sha256": osquerySHA},
+		{"eid": "3", "target_path": large, "action": "UPDATED", "time": timeStr},
+		{"eid": "4", "target_path": filepath.Join(dir, "gone.txt"), "action": "DELETED", "time": timeStr},
+	}, nil).Times(1)
+
+	require.NoError(it.checkFileChanges(context.Background()))
+
+	res, err := tracker.GetLogs(context.Background(), mongolog.LogQuery{Limit: 10})
+	require.NoError(err)
+	require.Len(res.Items, 4)
+
+	entries := make(map[string]mongolog.LogEntry)
+	for _, entry := range res.Items {
+		entries[entry.Details["target_path"]] = entry
+	}
+
+	sum := sha256.Sum256([]byte("hello"))
+	assert.Equal(hex.EncodeToString(sum[:]), entries[created].SHA256)
+	assert.Equal(entries[created].SHA256, entries[created].Details["sha256"])
+	assert.True(strings.HasPrefix(entries[created].FuzzyHash, "3:"))
+
+	// osquery's hash is kept, the fuzzy hash is added
+	assert.Equal(osquerySHA, entries[updated].SHA256)
+	assert.NotEmpty(entries[updated].FuzzyHash)
+
+	assert.Empty(entries[large].SHA256)
+	assert.Empty(entries[filepath.Join(dir, "gone.txt")].SHA256)
+}
+
+// go test -v -cover -run TestCheckFileChanges_HashesOnce ./pkg/filechangestracker
+func TestCheckFileChanges_HashesOnce(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	require.NoError(os.WriteFile(path, []byte("hello"), 0o644))
+
+	mockCtrl := gomock.NewController(t)
+	mockOSQueryManager := osquerymanagermock.NewMockOSQueryManager(mockCtrl)
+	mockMongolog := mongologmock.NewMockLogStore(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	cfg := &config.Config{
+		Directories:    []config.WatchConfig{{Path: dir, Recursive: true}},
+		HashingEnabled: true,
+		HashingWorkers: 1,
+	}
+	tracker := New(slog.Default(), cfg, NewOSQuerySource(mockOSQueryManager), mockMongolog, mockReporter, eventbus.New(slog.Default()))
+	it := tracker.(*fileChangesTracker)
+
+	// every idle check reads the event of the cursor's second again
+	timeStr := strconv.FormatInt(time.Now().Unix()+5, 10)
+	mockOSQueryManager.EXPECT().Query(gomock.Any()).DoAndReturn(func(string) ([]map[string]string, error) {
+		return []map[string]string{{"eid": "1", "target_path": path, "action": "CREATED", "time": timeStr}}, nil
+	}).Times(3)
+
+	// only the first check hashes and writes it
+	mockMongolog.EXPECT().WriteBatch(gomock.Any(), gomock.Len(1)).DoAndReturn(func(_ context.Context, rows []map[string]string) ([]mongolog.LogEntry, error) {
+		require.NotEmpty(rows[0]["sha256"])
+		return []mongolog.LogEntry{{ID: "1", Details: rows[0]}}, nil
+	}).Times(1)
+	mockReporter.EXPECT().Enqueue(gomock.Len(1)).Return(nil).Times(1)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(it.checkFileChanges(context.Background()))
+	}
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package filechangestracker
+
+import (
+	"io/fs"
+	"strconv"
+	"syscall"
+)
+
+// fileOwner returns the uid and gid of the file, as osquery reports them
+func fileOwner(info fs.FileInfo) (uid, gid string) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+
+	return strconv.FormatUint(uint64(stat.Uid), 10), strconv.FormatUint(uint64(stat.Gid), 10)
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package filechangestracker
+
+import (
+	"io/fs"
+)
+
+// fileOwner leaves the owner empty, windows files have no uid and gid
+func fileOwner(info fs.FileInfo) (uid, gid string) {
+	return "", ""
+}
@@ -0,0 +1,70 @@
+package filechangestracker
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/danielboakye/filechangestracker/pkg/osquerymanager"
+)
+
+// EventSource reads the file events of the watched directories. Rows are
+// shaped like osquery's file_events: target_path, action and time in unix
+// seconds, plus eid and the file attributes the source knows.
+type EventSource interface {
+	// Query returns the events below w from the second of since on, events
+	// in that second may have been returned before
+	Query(w watch, since int64) ([]map[string]string, error)
+	// Watch starts recording the events below w and Unwatch stops it,
+	// sources that see every directory anyway do nothing
+	Watch(w watch) error
+	Unwatch(w watch) error
+	Close() error
+}
+
+// osquerySource polls osquery's file_events table
+type osquerySource struct {
+	osqueryManager osquerymanager.OSQueryManager
+}
+
+func NewOSQuerySource(osqueryManager osquerymanager.OSQueryManager) EventSource {
+	return &osquerySource{
+		osqueryManager: osqueryManager,
+	}
+}
+
+func (s *osquerySource) Query(w watch, since int64) ([]map[string]string, error) {
+	q := osquerymanager.Select("file_events").
+		WhereLikePrefix("target_path", w.prefix()).
+		WhereGreaterThanOrEqual("time", since)
+	if len(w.Actions) > 0 {
+		q = q.WhereIn("action", w.Actions...)
+	}
+
+	query, err := q.Build()
+	if err != nil {
+		return nil, fmt.Errorf("error building query: %w", err)
+	}
+
+	rows, err := s.osqueryManager.Query(query)
+	if err != nil {
+		if errors.Is(err, osquerymanager.ErrNoChangesFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Watch does nothing, osquery records the directories of its own config
+func (s *osquerySource) Watch(w watch) error {
+	return nil
+}
+
+func (s *osquerySource) Unwatch(w watch) error {
+	return nil
+}
+
+func (s *osquerySource) Close() error {
+	return s.osqueryManager.Close()
+}
@@ -0,0 +1,44 @@
+package filechangestracker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/danielboakye/filechangestracker/pkg/atomicfile"
+)
+
+// readJSONFile decodes a state file from the data directory into v, ok is
+// false when the file does not exist yet.
+func readJSONFile(path string, v interface{}) (ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, v)
+	if err != nil {
+		return false, fmt.Errorf("error decoding %s: %w", path, err)
+	}
+
+	return true, nil
+}
+
+// writeJSONFile replaces the state file at path with v
+func writeJSONFile(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error encoding %s: %w", path, err)
+	}
+
+	err = atomicfile.WriteFile(path, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package filechangestracker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+)
+
+const watchesFileName = "watches.json"
+
+var (
+	ErrInvalidWatch  = errors.New("invalid watch")
+	ErrWatchExists   = errors.New("directory is already watched")
+	ErrWatchNotFound = errors.New("directory is not watched")
+)
+
+// watch applies a WatchConfig's rules to rows returned for its directory
+type watch struct {
+	config.WatchConfig
+	root string // Path without a trailing slash
+}
+
+func newWatch(cfg config.WatchConfig) watch {
+	root := strings.TrimRight(cfg.Path, "/")
+	if root == "" {
+		root = "/"
+	}
+
+	return watch{
+		WatchConfig: cfg,
+		root:        root,
+	}
+}
+
+// prefix is what paths inside the watched directory start with
+func (w watch) prefix() string {
+	if w.root == "/" {
+		return w.root
+	}
+
+	return w.root + "/"
+}
+
+// relativePath returns targetPath relative to the watched directory, ok is
+// false when targetPath is outside of it.
+func (w watch) relativePath(targetPath string) (rel string, ok bool) {
+	if w.root == "/" {
+		return strings.TrimPrefix(targetPath, "/"), strings.HasPrefix(targetPath, "/")
+	}
+	if !strings.HasPrefix(targetPath, w.root+"/") {
+		return "", false
+	}
+
+	return strings.TrimPrefix(targetPath, w.root+"/"), true
+}
+
+// matches reports whether a file_events row passes the watch's rules
+func (w watch) matches(row map[string]string) bool {
+	if len(w.Actions) > 0 && !contains(w.Actions, row["action"]) {
+		return false
+	}
+
+	return w.matchesPath(row["target_path"])
+}
+
+// matchesPath applies the watch's path rules, the action filter aside
+func (w watch) matchesPath(targetPath string) bool {
+	rel, ok := w.relativePath(targetPath)
+	if !ok || rel == "" {
+		return false
+	}
+	if !w.Recursive && strings.Contains(rel, "/") {
+		return false
+	}
+	if len(w.Include) > 0 && !matchesAny(w.Include, rel) {
+		return false
+	}
+	if matchesAny(w.Exclude, rel) {
+		return false
+	}
+
+	return true
+}
+
+// matchesAny checks patterns against both the file name and relative path
+func matchesAny(patterns []string, rel string) bool {
+	name := path.Base(rel)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreWatches replaces the configured watches with the ones saved by
+// the watches api, if any were saved. The saved file takes precedence over
+// directories in the config until it is deleted.
+func (f *fileChangesTracker) restoreWatches() error {
+	path := f.watchesPath()
+	if path == "" {
+		return nil
+	}
+
+	saved, ok, err := loadWatches(path)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+
+	watches := make([]watch, 0, len(saved))
+	for _, w := range saved {
+		err := config.ValidateWatch(&w)
+		if err != nil {
+			return fmt.Errorf("invalid saved watch %s: %w", w.Path, err)
+		}
+		watches = append(watches, newWatch(w))
+	}
+
+	f.watchesMu.Lock()
+	f.watches = watches
+	f.watchesMu.Unlock()
+
+	f.appLogger.Warn("using-saved-watches",
+		slog.String("file", path),
+		slog.Int("watches", len(watches)),
+		slog.String("note", "directories in the config are ignored, delete the file to use them"),
+	)
+
+	return nil
+}
+
+// watchesPath returns where runtime watch changes are persisted, empty when
+// the tracker has no data directory.
+func (f *fileChangesTracker) watchesPath() string {
+	if f.config.DataDir == "" {
+		return ""
+	}
+
+	return filepath.Join(f.config.DataDir, watchesFileName)
+}
+
+func (f *fileChangesTracker) currentWatches() []watch {
+	f.watchesMu.RLock()
+	defer f.watchesMu.RUnlock()
+
+	watches := make([]watch, len(f.watches))
+	copy(watches, f.watches)
+	return watches
+}
+
+func (f *fileChangesTracker) ListWatches() []config.WatchConfig {
+	watches := f.currentWatches()
+
+	res := make([]config.WatchConfig, 0, len(watches))
+	for _, w := range watches {
+		res = append(res, w.WatchConfig)
+	}
+	return res
+}
+
+func (f *fileChangesTracker) AddWatch(w config.WatchConfig) (config.WatchConfig, error) {
+	err := config.ValidateWatch(&w)
+	if err != nil {
+		return w, fmt.Errorf("%w: %v", ErrInvalidWatch, err)
+	}
+	added := newWatch(w)
+
+	f.watchesMu.Lock()
+	defer f.watchesMu.Unlock()
+
+	for _, existing := range f.watches {
+		if existing.root == added.root {
+			return w, ErrWatchExists
+		}
+	}
+
+	watches := append(append([]watch{}, f.watches...), added)
+	err = f.saveWatches(watches)
+	if err != nil {
+		return w, err
+	}
+	f.watches = watches
+	f.watchSource(added)
+
+	f.appLogger.Info("added-watch", slog.String("directory", w.Path), slog.Bool("recursive", w.Recursive))
+
+	return w, nil
+}
+
+func (f *fileChangesTracker) RemoveWatch(path string) error {
+	removed := config.WatchConfig{Path: path}
+	err := config.ValidateWatch(&removed)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidWatch, err)
+	}
+	root := newWatch(removed).root
+
+	f.watchesMu.Lock()
+	defer f.watchesMu.Unlock()
+
+	var unwatched []watch
+	watches := make([]watch, 0, len(f.watches))
+	for _, existing := range f.watches {
+		if existing.root == root {
+			unwatched = append(unwatched, existing)
+			continue
+		}
+		watches = append(watches, existing)
+	}
+	if len(unwatched) == 0 {
+		return ErrWatchNotFound
+	}
+
+	err = f.saveWatches(watches)
+	if err != nil {
+		return err
+	}
+	f.watches = watches
+	for _, w := range unwatched {
+		err := f.source.Unwatch(w)
+		if err != nil {
+			f.appLogger.Error("error-unwatching-directory", slog.String("directory", w.Path), slog.String("error", err.Error()))
+		}
+	}
+
+	f.appLogger.Info("removed-watch", slog.String("directory", removed.Path))
+
+	return nil
+}
+
+// watchSource has the event source record the events of w from now on. A
+// root that fails, like one that does not exist yet, is tried again on
+// every check.
+func (f *fileChangesTracker) watchSource(w watch) {
+	err := f.source.Watch(w)
+	if err != nil {
+		f.appLogger.Warn("error-watching-directory", slog.String("directory", w.Path), slog.String("error", err.Error()))
+	}
+}
+
+func (f *fileChangesTracker) saveWatches(watches []watch) error {
+	path := f.watchesPath()
+	if path == "" {
+		return nil
+	}
+
+	configs := make([]config.WatchConfig, 0, len(watches))
+	for _, w := range watches {
+		configs = append(configs, w.WatchConfig)
+	}
+
+	err := writeJSONFile(path, configs)
+	if err != nil {
+		return fmt.Errorf("error saving watches: %w", err)
+	}
+
+	return nil
+}
+
+func loadWatches(path string) (watches []config.WatchConfig, ok bool, err error) {
+	ok, err = readJSONFile(path, &watches)
+	if err != nil {
+		return nil, false, fmt.Errorf("error loading watches: %w", err)
+	}
+
+	return watches, ok, nil
+}
@@ -0,0 +1,109 @@
+package filechangestracker
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestWatchMatches ./internal/filechangestracker
+func TestWatchMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		watch    config.WatchConfig
+		path     string
+		action   string
+		expected bool
+	}{
+		{"File in directory", config.WatchConfig{Path: "/tmp/dl/"}, "/tmp/dl/a.txt", "CREATED", true},
+		{"Sibling with shared prefix", config.WatchConfig{Path: "/tmp/dl"}, "/tmp/dl2/a.txt", "CREATED", false},
+		{"Directory itself", config.WatchConfig{Path: "/tmp/dl"}, "/tmp/dl", "UPDATED", false},
+		{"Nested file, not recursive", config.WatchConfig{Path: "/tmp/dl"}, "/tmp/dl/sub/a.txt", "CREATED", false},
+		{"Nested file, recursive", config.WatchConfig{Path: "/tmp/dl", Recursive: true}, "/tmp/dl/sub/a.txt", "CREATED", true},
+		{"Action filtered out", config.WatchConfig{Path: "/tmp/dl", Actions: []string{"DELETED"}}, "/tmp/dl/a.txt", "CREATED", false},
+		{"Action allowed", config.WatchConfig{Path: "/tmp/dl", Actions: []string{"CREATED", "DELETED"}}, "/tmp/dl/a.txt", "DELETED", true},
+		{"Include by name", config.WatchConfig{Path: "/tmp/dl", Recursive: true, Include: []string{"*.pdf"}}, "/tmp/dl/sub/a.pdf", "CREATED", true},
+		{"Include misses", config.WatchConfig{Path: "/tmp/dl", Include: []string{"*.pdf"}}, "/tmp/dl/a.txt", "CREATED", false},
+		{"Include by relative path", config.WatchConfig{Path: "/tmp/dl", Recursive: true, Include: []string{"sub/*"}}, "/tmp/dl/sub/a.txt", "CREATED", true},
+		{"Exclude wins over include", config.WatchConfig{Path: "/tmp/dl", Include: []string{"*"}, Exclude: []string{"*.crdownload"}}, "/tmp/dl/a.crdownload", "UPDATED", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newWatch(tt.watch)
+			result := w.matches(map[string]string{
+				"target_path": tt.path,
+				"action":      tt.action,
+			})
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+// go test -v -cover -run TestAddRemoveWatch ./internal/filechangestracker
+func TestAddRemoveWatch(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cfg := &config.Config{
+		DataDir:     t.TempDir(),
+		Directories: []config.WatchConfig{{Path: "/tmp/downloads", Recursive: true}},
+	}
+	it := New(slog.Default(), cfg, NewOSQuerySource(nil), nil, nil, nil).(*fileChangesTracker)
+
+	_, err := it.AddWatch(config.WatchConfig{Path: "/tmp/documents/", Actions: []string{"created"}})
+	require.NoError(err)
+
+	_, err = it.AddWatch(config.WatchConfig{Path: "/tmp/documents"})
+	assert.ErrorIs(err, ErrWatchExists)
+
+	_, err = it.AddWatch(config.WatchConfig{Path: "relative/dir"})
+	assert.ErrorIs(err, ErrInvalidWatch)
+
+	err = it.RemoveWatch("/tmp/./downloads//")
+	require.NoError(err)
+
+	err = it.RemoveWatch("/tmp/downloads")
+	assert.ErrorIs(err, ErrWatchNotFound)
+
+	err = it.RemoveWatch("relative/dir")
+	assert.ErrorIs(err, ErrInvalidWatch)
+
+	// a restarted tracker picks up the saved watches instead of the config
+	restarted := New(slog.Default(), cfg, NewOSQuerySource(nil), nil, nil, nil).(*fileChangesTracker)
+	require.NoError(restarted.restoreWatches())
+
+	watches := restarted.ListWatches()
+	require.Len(watches, 1)
+	assert.Equal("/tmp/documents", watches[0].Path)
+	assert.Equal([]string{"CREATED"}, watches[0].Actions)
+}
+
+// go test -v -cover -run TestAddRemoveWatch_FSNotify ./internal/filechangestracker
+func TestAddRemoveWatch_FSNotify(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.NoError(os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+
+	source, err := NewFSNotifySource(slog.Default())
+	require.NoError(err)
+	defer source.Close()
+	watcher := source.(*fsnotifySource).watcher
+
+	it := New(slog.Default(), &config.Config{}, source, nil, nil, nil).(*fileChangesTracker)
+
+	// an added directory is watched before the first check
+	_, err = it.AddWatch(config.WatchConfig{Path: dir, Recursive: true})
+	require.NoError(err)
+	assert.ElementsMatch([]string{dir, filepath.Join(dir, "sub")}, watcher.WatchList())
+
+	require.NoError(it.RemoveWatch(dir))
+	assert.Empty(watcher.WatchList())
+}
@@ -1,29 +1,124 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/danielboakye/filechangestracker/internal/commandexecutor"
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/filechangestracker"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/danielboakye/filechangestracker/internal/reporter"
+	"github.com/danielboakye/filechangestracker/internal/snapshot"
 	"github.com/danielboakye/filechangestracker/pkg/response"
+	"github.com/go-chi/chi"
 )
 
 // CommandRequest represents the structure of a command request
 type CommandRequest struct {
 	Commands []string `json:"commands"`
+
+	// OrderingKey makes these commands run after earlier commands submitted
+	// with the same key, the commands of one request always run in order
+	OrderingKey string `json:"ordering_key,omitempty"`
+}
+
+// queueFullRetryAfter is how long clients are asked to wait when the command queue is full
+const queueFullRetryAfter = 5 * time.Second
+
+// CommandResponse represents the structure of a command submission response
+type CommandResponse struct {
+	Message string                `json:"message"`
+	Jobs    []commandexecutor.Job `json:"jobs"`
 }
 
 // HealthCheckResponse represents the structure of the health check response
 type HealthCheckResponse struct {
-	WorkerThread bool `json:"worker_thread_alive"`
-	TimerThread  bool `json:"timer_thread_alive"`
+	WorkerThread bool                        `json:"worker_thread_alive"`
+	TimerThread  bool                        `json:"timer_thread_alive"`
+	Reporter     reporter.DeliveryStatus     `json:"reporter"`
+	CommandQueue commandexecutor.QueueStatus `json:"command_queue"`
+}
+
+// requestError is an error caused by the caller's input
+type requestError struct {
+	message string
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// LogsRequest holds the parameters of a logs request, they mirror the query
+// parameters of GET /v1/logs
+type LogsRequest struct {
+	Limit  int64            `json:"limit"`
+	Offset int64            `json:"offset"`
+	Cursor string           `json:"cursor"`
+	Filter LogFilterRequest `json:"filter"`
 }
 
-// LogsResponse represents the structure of logs response
+// LogFilterRequest is the unparsed log filter, times are RFC 3339 or unix
+// seconds
+type LogFilterRequest struct {
+	PathPrefix string   `json:"path_prefix"`
+	PathGlob   string   `json:"path_glob"`
+	Actions    []string `json:"actions"`
+	Since      string   `json:"since"`
+	Until      string   `json:"until"`
+	MD5        string   `json:"md5"`
+	SHA256     string   `json:"sha256"`
+}
+
+// LogsResponse represents the structure of logs response, the next page is
+// requested by passing NextCursor as the cursor parameter or NextOffset as
+// the offset parameter. Both are left out on the last page.
 type LogsResponse struct {
-	Logs []string `json:"logs"`
+	Items          []mongolog.LogEntry `json:"items"`
+	Total          int64               `json:"total"`
+	TotalEstimated bool                `json:"total_estimated"`
+	Limit          int64               `json:"limit"`
+	Offset         int64               `json:"offset"`
+	NextOffset     *int64              `json:"next_offset,omitempty"` // only when paging by offset
+	NextCursor     string              `json:"next_cursor,omitempty"`
+	Filters        LogFilters          `json:"filters"`
+}
+
+// LogFilters are the filters a logs response was computed with
+type LogFilters struct {
+	PathPrefix string     `json:"path_prefix,omitempty"`
+	PathGlob   string     `json:"path_glob,omitempty"`
+	Actions    []string   `json:"actions,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	Until      *time.Time `json:"until,omitempty"`
+	MD5        string     `json:"md5,omitempty"`
+	SHA256     string     `json:"sha256,omitempty"`
+}
+
+func newLogFilters(filter mongolog.LogFilter) LogFilters {
+	res := LogFilters{
+		PathPrefix: filter.PathPrefix,
+		PathGlob:   filter.PathGlob,
+		Actions:    filter.Actions,
+		MD5:        filter.MD5,
+		SHA256:     filter.SHA256,
+	}
+	if !filter.Since.IsZero() {
+		res.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		res.Until = &filter.Until
+	}
+
+	return res
 }
 
 func (h *Handler) HandleSubmitCommands(w http.ResponseWriter, r *http.Request) {
@@ -44,34 +139,91 @@ func (h *Handler) HandleSubmitCommands(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.executor.AddCommands(req.Commands)
+	jobs, err := h.executor.AddCommands(req.OrderingKey, req.Commands)
 	if err != nil {
-		response.InternalError(w)
+		switch {
+		case errors.Is(err, commandexecutor.ErrCommandRejected):
+			response.InvalidRequest(w, err.Error())
+		case errors.Is(err, commandexecutor.ErrBatchTooLarge):
+			response.JSON(w, http.StatusRequestEntityTooLarge, err)
+		case errors.Is(err, commandexecutor.ErrQueueFull):
+			w.Header().Set("Retry-After", strconv.Itoa(int(queueFullRetryAfter.Seconds())))
+			response.JSON(w, http.StatusTooManyRequests, err)
+		case errors.Is(err, commandexecutor.ErrExecutorStopped):
+			response.JSON(w, http.StatusServiceUnavailable, err)
+		default:
+			response.InternalError(w)
+		}
 		return
 	}
 
-	response.JSON(w, http.StatusOK, map[string]string{
-		"message": "commands added to queue",
+	response.JSON(w, http.StatusOK, CommandResponse{
+		Message: "commands added to queue",
+		Jobs:    jobs,
 	})
 }
 
+// HandleGetCommand returns the status and result of a submitted command
+func (h *Handler) HandleGetCommand(w http.ResponseWriter, r *http.Request) {
+	job, err := h.executor.GetJob(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, commandexecutor.ErrJobNotFound) {
+			response.JSON(w, http.StatusNotFound, err)
+			return
+		}
+		response.InternalError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, job)
+}
+
+// HandleCancelCommand cancels a queued command or interrupts a running one
+func (h *Handler) HandleCancelCommand(w http.ResponseWriter, r *http.Request) {
+	job, err := h.executor.CancelJob(chi.URLParam(r, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, commandexecutor.ErrJobNotFound):
+			response.JSON(w, http.StatusNotFound, err)
+		case errors.Is(err, commandexecutor.ErrJobFinished):
+			response.JSON(w, http.StatusConflict, err)
+		default:
+			response.InternalError(w)
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusAccepted, job)
+}
+
 // handleHealthCheck returns the health status of the worker and timer threads
 func (h *Handler) HandleHealthCheck(w http.ResponseWriter, r *http.Request) {
-	res := HealthCheckResponse{
+	response.JSON(w, http.StatusOK, h.Health())
+}
+
+// Health reports the state of the background threads, the reporter and the
+// command queue
+func (h *Handler) Health() HealthCheckResponse {
+	return HealthCheckResponse{
 		WorkerThread: h.executor.IsWorkerThreadAlive(),
 		TimerThread:  h.tracker.IsTimerThreadAlive(),
+		Reporter:     h.reporter.Status(),
+		CommandQueue: h.executor.QueueStatus(),
 	}
-
-	response.JSON(w, http.StatusOK, res)
 }
 
 func (h *Handler) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
-	var offset, limit int64 = 0, 10
+	req := LogsRequest{
+		Limit:  10,
+		Cursor: q.Get("cursor"),
+		Filter: parseLogFilterRequest(q),
+	}
+
 	var err error
 	queryOffset := q.Get("offset")
 	if queryOffset != "" {
-		offset, err = strconv.ParseInt(queryOffset, 10, 64)
+		req.Offset, err = strconv.ParseInt(queryOffset, 10, 64)
 		if err != nil {
 			response.InvalidRequest(w, "offset field is not an integer")
 			return
@@ -80,19 +232,24 @@ func (h *Handler) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 
 	queryLimit := q.Get("limit")
 	if queryLimit != "" {
-		limit, err = strconv.ParseInt(queryLimit, 10, 64)
+		req.Limit, err = strconv.ParseInt(queryLimit, 10, 64)
 		if err != nil {
 			response.InvalidRequest(w, "limit field is not an integer")
 			return
 		}
 	}
-	if limit < 1 {
+	if req.Limit < 1 {
 		response.InvalidRequest(w, "limit field cannot be less than 1")
 		return
 	}
 
-	res, err := h.tracker.GetLogs(r.Context(), limit, offset)
+	res, err := h.Logs(r.Context(), req)
 	if err != nil {
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			response.InvalidRequest(w, reqErr.message)
+			return
+		}
 		response.InternalError(w)
 		return
 	}
@@ -100,8 +257,378 @@ func (h *Handler) HandleGetLogs(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusOK, res)
 }
 
+// Logs returns a page of logs, it backs GET /v1/logs and the desktop UI. A
+// zero limit reads the default page size.
+func (h *Handler) Logs(ctx context.Context, req LogsRequest) (LogsResponse, error) {
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+	if req.Limit < 0 {
+		return LogsResponse{}, &requestError{"limit field cannot be less than 1"}
+	}
+	if req.Offset < 0 {
+		return LogsResponse{}, &requestError{"offset field cannot be negative"}
+	}
+	if req.Cursor != "" && req.Offset != 0 {
+		return LogsResponse{}, &requestError{"cursor and offset fields cannot be combined"}
+	}
+
+	filter, err := req.Filter.logFilter()
+	if err != nil {
+		return LogsResponse{}, &requestError{err.Error()}
+	}
+
+	page, err := h.tracker.GetLogs(ctx, mongolog.LogQuery{
+		Filter: filter,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+		Cursor: req.Cursor,
+	})
+	if err != nil {
+		if errors.Is(err, mongolog.ErrInvalidCursor) {
+			return LogsResponse{}, &requestError{err.Error()}
+		}
+		return LogsResponse{}, err
+	}
+
+	res := LogsResponse{
+		Items:          page.Items,
+		Total:          page.Total,
+		TotalEstimated: page.TotalEstimated,
+		Limit:          req.Limit,
+		Offset:         req.Offset,
+		NextCursor:     page.NextCursor,
+		Filters:        newLogFilters(filter),
+	}
+	if res.Items == nil {
+		res.Items = []mongolog.LogEntry{}
+	}
+	if req.Cursor == "" && page.NextCursor != "" {
+		nextOffset := req.Offset + int64(len(page.Items))
+		res.NextOffset = &nextOffset
+	}
+
+	return res, nil
+}
+
+// WatchRequest represents the structure of a request to watch a directory
+type WatchRequest struct {
+	Path      string   `json:"path"`
+	Recursive *bool    `json:"recursive"` // defaults to true, like in config.yaml
+	Include   []string `json:"include"`
+	Exclude   []string `json:"exclude"`
+	Actions   []string `json:"actions"`
+}
+
+// WatchesResponse represents the structure of the watched directories response
+type WatchesResponse struct {
+	Watches []config.WatchConfig `json:"watches"`
+}
+
+func (h *Handler) HandleListWatches(w http.ResponseWriter, r *http.Request) {
+	response.JSON(w, http.StatusOK, WatchesResponse{
+		Watches: h.tracker.ListWatches(),
+	})
+}
+
+func (h *Handler) HandleAddWatch(w http.ResponseWriter, r *http.Request) {
+	var req WatchRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.InvalidRequest(w, err.Error())
+		return
+	}
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		response.InvalidRequest(w, err.Error())
+		return
+	}
+
+	recursive := true
+	if req.Recursive != nil {
+		recursive = *req.Recursive
+	}
+
+	watch, err := h.tracker.AddWatch(config.WatchConfig{
+		Path:      req.Path,
+		Recursive: recursive,
+		Include:   req.Include,
+		Exclude:   req.Exclude,
+		Actions:   req.Actions,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, filechangestracker.ErrInvalidWatch):
+			response.InvalidRequest(w, err.Error())
+		case errors.Is(err, filechangestracker.ErrWatchExists):
+			response.JSON(w, http.StatusConflict, err)
+		default:
+			response.InternalError(w)
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, watch)
+}
+
+func (h *Handler) HandleRemoveWatch(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		response.InvalidRequest(w, "path field is required")
+		return
+	}
+
+	err := h.tracker.RemoveWatch(path)
+	if err != nil {
+		switch {
+		case errors.Is(err, filechangestracker.ErrInvalidWatch):
+			response.InvalidRequest(w, err.Error())
+		case errors.Is(err, filechangestracker.ErrWatchNotFound):
+			response.JSON(w, http.StatusNotFound, err)
+		default:
+			response.InternalError(w)
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, map[string]string{
+		"message": "directory is no longer watched",
+	})
+}
+
+// HandleScan starts a baseline scan and returns 202 with its status, poll
+// GET /v1/scans/{id} for the summary. The drift it finds is logged like any
+// other change.
+func (h *Handler) HandleScan(w http.ResponseWriter, r *http.Request) {
+	status, err := h.tracker.StartScan()
+	if err != nil {
+		if errors.Is(err, filechangestracker.ErrScanInProgress) {
+			response.JSON(w, http.StatusConflict, err)
+			return
+		}
+		response.InternalError(w)
+		return
+	}
+
+	w.Header().Set("Location", "/v1/scans/"+status.ID)
+	response.JSON(w, http.StatusAccepted, status)
+}
+
+// HandleGetScan returns the status of a scan, "latest" for the last one
+func (h *Handler) HandleGetScan(w http.ResponseWriter, r *http.Request) {
+	status, err := h.tracker.GetScan(chi.URLParam(r, "id"))
+	if err != nil {
+		if errors.Is(err, filechangestracker.ErrScanNotFound) {
+			response.JSON(w, http.StatusNotFound, err)
+			return
+		}
+		response.InternalError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, status)
+}
+
+// FileVersionsResponse represents the structure of the file versions response
+type FileVersionsResponse struct {
+	Path     string             `json:"path"`
+	Versions []snapshot.Version `json:"versions"`
+}
+
+// FileDiffResponse represents the structure of the file diff response
+type FileDiffResponse struct {
+	Path string `json:"path"`
+	From string `json:"from"`
+	To   string `json:"to,omitempty"` // empty when compared with the current file
+	Diff string `json:"diff"`
+}
+
+// RestoreRequest represents the structure of a file restore request
+type RestoreRequest struct {
+	Path      string `json:"path"`
+	VersionID string `json:"version_id"`
+}
+
+var errSnapshotsDisabled = errors.New("file snapshots are disabled")
+
+func (h *Handler) HandleListFileVersions(w http.ResponseWriter, r *http.Request) {
+	if h.snapshots == nil {
+		response.JSON(w, http.StatusNotFound, errSnapshotsDisabled)
+		return
+	}
+
+	path := r.URL.Query().Get("path")
+	if path == "" {
+		response.InvalidRequest(w, "path field is required")
+		return
+	}
+
+	versions, err := h.snapshots.Versions(path)
+	if err != nil {
+		response.InternalError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, FileVersionsResponse{
+		Path:     path,
+		Versions: versions,
+	})
+}
+
+// HandleFileDiff returns the unified diff between two versions of a text
+// file, or between a version and the file as it is now when to is empty
+func (h *Handler) HandleFileDiff(w http.ResponseWriter, r *http.Request) {
+	if h.snapshots == nil {
+		response.JSON(w, http.StatusNotFound, errSnapshotsDisabled)
+		return
+	}
+
+	q := r.URL.Query()
+	path, from, to := q.Get("path"), q.Get("from"), q.Get("to")
+	if path == "" || from == "" {
+		response.InvalidRequest(w, "path and from fields are required")
+		return
+	}
+
+	diff, err := h.snapshots.Diff(path, from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, snapshot.ErrVersionNotFound):
+			response.JSON(w, http.StatusNotFound, err)
+		case errors.Is(err, snapshot.ErrNotText):
+			response.InvalidRequest(w, err.Error())
+		default:
+			response.InternalError(w)
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, FileDiffResponse{
+		Path: path,
+		From: from,
+		To:   to,
+		Diff: diff,
+	})
+}
+
+// HandleRestoreFile writes a version back to its path, the path must pass
+// the command policy checks
+func (h *Handler) HandleRestoreFile(w http.ResponseWriter, r *http.Request) {
+	if h.snapshots == nil {
+		response.JSON(w, http.StatusNotFound, errSnapshotsDisabled)
+		return
+	}
+
+	var req RestoreRequest
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		response.InvalidRequest(w, err.Error())
+		return
+	}
+
+	err = json.Unmarshal(body, &req)
+	if err != nil {
+		response.InvalidRequest(w, err.Error())
+		return
+	}
+	if req.Path == "" || req.VersionID == "" {
+		response.InvalidRequest(w, "path and version_id fields are required")
+		return
+	}
+
+	version, err := h.snapshots.Restore(req.Path, req.VersionID)
+	if err != nil {
+		switch {
+		case errors.Is(err, snapshot.ErrVersionNotFound):
+			response.JSON(w, http.StatusNotFound, err)
+		case errors.Is(err, commandexecutor.ErrCommandRejected):
+			response.JSON(w, http.StatusForbidden, err)
+		default:
+			response.InternalError(w)
+		}
+		return
+	}
+
+	response.JSON(w, http.StatusOK, version)
+}
+
 func (h *Handler) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	response.JSON(w, http.StatusNotFound, map[string]string{
 		"message": fmt.Sprintf("resource: (%s) could not be found", r.URL.Path),
 	})
 }
+
+// parseLogFilterRequest reads the log filter from the query parameters,
+// action may be repeated or comma separated
+func parseLogFilterRequest(q url.Values) LogFilterRequest {
+	req := LogFilterRequest{
+		PathPrefix: q.Get("path_prefix"),
+		PathGlob:   q.Get("path_glob"),
+		Since:      q.Get("since"),
+		Until:      q.Get("until"),
+		MD5:        q.Get("md5"),
+		SHA256:     q.Get("sha256"),
+	}
+
+	for _, actions := range q["action"] {
+		for _, action := range strings.Split(actions, ",") {
+			if action != "" {
+				req.Actions = append(req.Actions, action)
+			}
+		}
+	}
+
+	return req
+}
+
+func parseLogFilter(q url.Values) (mongolog.LogFilter, error) {
+	return parseLogFilterRequest(q).logFilter()
+}
+
+// logFilter parses the times and validates the filter
+func (req LogFilterRequest) logFilter() (mongolog.LogFilter, error) {
+	filter := mongolog.LogFilter{
+		PathPrefix: req.PathPrefix,
+		PathGlob:   req.PathGlob,
+		Actions:    append([]string(nil), req.Actions...),
+		MD5:        req.MD5,
+		SHA256:     req.SHA256,
+	}
+
+	var err error
+	filter.Since, err = parseTime(req.Since)
+	if err != nil {
+		return filter, fmt.Errorf("since field: %w", err)
+	}
+	filter.Until, err = parseTime(req.Until)
+	if err != nil {
+		return filter, fmt.Errorf("until field: %w", err)
+	}
+
+	err = filter.Validate()
+	if err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// parseTime accepts RFC 3339 or unix seconds, empty is the zero time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC 3339 or unix seconds")
+	}
+
+	return t, nil
+}
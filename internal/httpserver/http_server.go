@@ -3,6 +3,7 @@ package httpserver
 import (
 	"context"
 	"log/slog"
+	"net"
 	"net/http"
 )
 
@@ -18,12 +19,19 @@ func NewServer(
 	logger *slog.Logger,
 	handler http.Handler,
 ) *Server {
+	// request contexts are canceled on shutdown so long lived streams end
+	// instead of holding Shutdown until its deadline
+	baseCtx, cancel := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Addr:        addr,
+		Handler:     handler,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+	httpServer.RegisterOnShutdown(cancel)
+
 	return &Server{
-		logger: logger,
-		httpServer: &http.Server{
-			Addr:    addr,
-			Handler: handler,
-		},
+		logger:     logger,
+		httpServer: httpServer,
 	}
 }
 
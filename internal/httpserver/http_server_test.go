@@ -1,7 +1,9 @@
 package httpserver
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
@@ -10,9 +12,17 @@ import (
 	"testing"
 	"time"
 
+	"github.com/danielboakye/filechangestracker/internal/commandexecutor"
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/filechangestracker"
 	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/danielboakye/filechangestracker/internal/reporter"
+	"github.com/danielboakye/filechangestracker/internal/snapshot"
 	commandexecutormock "github.com/danielboakye/filechangestracker/mocks/commandexecutor"
 	filechangestrackermock "github.com/danielboakye/filechangestracker/mocks/filechangestracker"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	snapshotmock "github.com/danielboakye/filechangestracker/mocks/snapshot"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -21,28 +31,51 @@ import (
 
 // go test -v -cover ./pkg/httpserver/...
 
+// testHandler serves the routes of a handler over mocks
+type testHandler struct {
+	handler   *Handler
+	executor  *commandexecutormock.MockCommandExecutor
+	tracker   *filechangestrackermock.MockFileChangesTracker
+	reporter  *reportermock.MockReporter
+	snapshots *snapshotmock.MockStore
+}
+
+func newTestHandler(t *testing.T) *testHandler {
+	mockCtrl := gomock.NewController(t)
+	th := &testHandler{
+		executor:  commandexecutormock.NewMockCommandExecutor(mockCtrl),
+		tracker:   filechangestrackermock.NewMockFileChangesTracker(mockCtrl),
+		reporter:  reportermock.NewMockReporter(mockCtrl),
+		snapshots: snapshotmock.NewMockStore(mockCtrl),
+	}
+	th.handler = NewHandler(th.tracker, th.executor, th.reporter, eventbus.New(slog.Default()), th.snapshots)
+
+	return th
+}
+
+// ServeHTTP runs r through the routes as the server does
+func (th *testHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	apiServer := NewServer(":9000", slog.Default(), th.handler.RegisterRoutes())
+	apiServer.httpServer.Handler.ServeHTTP(w, r)
+}
+
 // go test -v -cover -run TestHealthCheck ./pkg/httpserver
 func TestHealthCheck(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
 
-	mockCtrl := gomock.NewController(t)
-	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
-	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
-
-	appLogger := slog.Default()
-	handler := NewHandler(mockFileTracker, mockCmdExecutor)
-	router := handler.RegisterRoutes()
-	apiServer := NewServer(":9000", appLogger, router)
+	th := newTestHandler(t)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/v1/health", nil)
 	r.Header.Set("Content-Type", "application/json")
 
-	mockCmdExecutor.EXPECT().IsWorkerThreadAlive().Return(true).Times(1)
-	mockFileTracker.EXPECT().IsTimerThreadAlive().Return(true).Times(1)
+	th.executor.EXPECT().IsWorkerThreadAlive().Return(true).Times(1)
+	th.tracker.EXPECT().IsTimerThreadAlive().Return(true).Times(1)
+	th.reporter.EXPECT().Status().Return(reporter.DeliveryStatus{Pending: 2}).Times(1)
+	th.executor.EXPECT().QueueStatus().Return(commandexecutor.QueueStatus{Depth: 3, Capacity: 100}).Times(1)
 
-	apiServer.httpServer.Handler.ServeHTTP(w, r)
+	th.ServeHTTP(w, r)
 
 	assert.Equal(http.StatusOK, w.Code)
 
@@ -52,6 +85,9 @@ func TestHealthCheck(t *testing.T) {
 
 	assert.True(res.TimerThread)
 	assert.True(res.WorkerThread)
+	assert.Equal(2, res.Reporter.Pending)
+	assert.Equal(3, res.CommandQueue.Depth)
+	assert.Equal(100, res.CommandQueue.Capacity)
 }
 
 // go test -v -cover -run TestSubmitCommands ./pkg/httpserver
@@ -59,52 +95,157 @@ func TestSubmitCommands(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
 
-	mockCtrl := gomock.NewController(t)
-	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
-	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
-
-	appLogger := slog.Default()
-	handler := NewHandler(mockFileTracker, mockCmdExecutor)
-	router := handler.RegisterRoutes()
-	apiServer := NewServer(":9000", appLogger, router)
+	th := newTestHandler(t)
 
 	w := httptest.NewRecorder()
-	r := httptest.NewRequest(http.MethodPost, "/v1/commands", strings.NewReader(`{"commands":["touch /Users/user/Downloads/test/test.txt"]}`))
+	r := httptest.NewRequest(http.MethodPost, "/v1/commands", strings.NewReader(`{"commands":["touch /Users/user/Downloads/test/test.txt"],"ordering_key":"downloads"}`))
 	r.Header.Set("Content-Type", "application/json")
 
-	mockCmdExecutor.EXPECT().AddCommands(gomock.Any()).Return(nil).Times(1)
+	jobID := uuid.NewString()
+	th.executor.EXPECT().AddCommands("downloads", []string{"touch /Users/user/Downloads/test/test.txt"}).Return([]commandexecutor.Job{
+		{ID: jobID, Command: "touch /Users/user/Downloads/test/test.txt", Status: commandexecutor.JobStatusQueued},
+	}, nil).Times(1)
 
-	apiServer.httpServer.Handler.ServeHTTP(w, r)
+	th.ServeHTTP(w, r)
 
 	assert.Equal(http.StatusOK, w.Code)
 
-	res := map[string]string{}
+	res := CommandResponse{}
 	err := json.Unmarshal(w.Body.Bytes(), &res)
 	require.NoError(err)
 
-	msg, exists := res["message"]
-	assert.True(exists)
-	assert.Equal(msg, "commands added to queue")
+	assert.Equal(res.Message, "commands added to queue")
+	require.Len(res.Jobs, 1)
+	assert.Equal(jobID, res.Jobs[0].ID)
+	assert.Equal(commandexecutor.JobStatusQueued, res.Jobs[0].Status)
+}
+
+// go test -v -cover -run TestSubmitCommands_Rejected ./pkg/httpserver
+func TestSubmitCommands_Rejected(t *testing.T) {
+	assert := assert.New(t)
+
+	th := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/commands", strings.NewReader(`{"commands":["touch /etc/anything"]}`))
+	r.Header.Set("Content-Type", "application/json")
+
+	th.executor.EXPECT().AddCommands(gomock.Any(), gomock.Any()).Return(nil, commandexecutor.ErrCommandRejected).Times(1)
+
+	th.ServeHTTP(w, r)
+
+	assert.Equal(http.StatusBadRequest, w.Code)
+}
+
+// go test -v -cover -run TestSubmitCommands_Backpressure ./pkg/httpserver
+func TestSubmitCommands_Backpressure(t *testing.T) {
+	tests := []struct {
+		name               string
+		executorErr        error
+		expectedCode       int
+		expectedRetryAfter string
+	}{
+		{"Queue full", commandexecutor.ErrQueueFull, http.StatusTooManyRequests, "5"},
+		{"Batch too large", commandexecutor.ErrBatchTooLarge, http.StatusRequestEntityTooLarge, ""},
+		{"Executor stopped", commandexecutor.ErrExecutorStopped, http.StatusServiceUnavailable, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/commands", strings.NewReader(`{"commands":["touch /tmp/test.txt"]}`))
+			r.Header.Set("Content-Type", "application/json")
+
+			th.executor.EXPECT().AddCommands(gomock.Any(), gomock.Any()).Return(nil, tt.executorErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			assert.Equal(t, tt.expectedRetryAfter, w.Header().Get("Retry-After"))
+		})
+	}
+}
+
+// go test -v -cover -run TestGetCommand ./pkg/httpserver
+func TestGetCommand(t *testing.T) {
+	exitCode := 0
+	tests := []struct {
+		name         string
+		job          commandexecutor.Job
+		executorErr  error
+		expectedCode int
+	}{
+		{"Finished job", commandexecutor.Job{ID: "job-1", Status: commandexecutor.JobStatusSucceeded, ExitCode: &exitCode}, nil, http.StatusOK},
+		{"Unknown job", commandexecutor.Job{}, commandexecutor.ErrJobNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/commands/job-1", nil)
+
+			th.executor.EXPECT().GetJob("job-1").Return(tt.job, tt.executorErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode != http.StatusOK {
+				return
+			}
+
+			res := commandexecutor.Job{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, commandexecutor.JobStatusSucceeded, res.Status)
+			assert.Equal(t, 0, *res.ExitCode)
+		})
+	}
+}
+
+// go test -v -cover -run TestCancelCommand ./pkg/httpserver
+func TestCancelCommand(t *testing.T) {
+	tests := []struct {
+		name         string
+		executorErr  error
+		expectedCode int
+	}{
+		{"Job canceled", nil, http.StatusAccepted},
+		{"Unknown job", commandexecutor.ErrJobNotFound, http.StatusNotFound},
+		{"Finished job", commandexecutor.ErrJobFinished, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, "/v1/commands/job-1", nil)
+
+			job := commandexecutor.Job{ID: "job-1", Status: commandexecutor.JobStatusCanceled}
+			th.executor.EXPECT().CancelJob("job-1").Return(job, tt.executorErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
 }
 
 // go test -v -cover -run TestSubmitCommands_Failed ./pkg/httpserver
 func TestSubmitCommands_Failed(t *testing.T) {
 	assert := assert.New(t)
 
-	mockCtrl := gomock.NewController(t)
-	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
-	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
-
-	appLogger := slog.Default()
-	handler := NewHandler(mockFileTracker, mockCmdExecutor)
-	router := handler.RegisterRoutes()
-	apiServer := NewServer(":9000", appLogger, router)
+	th := newTestHandler(t)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodPost, "/v1/commands", strings.NewReader(`{"commands": "touch /Users/user/Downloads/test/test.txt"}`))
 	r.Header.Set("Content-Type", "application/json")
 
-	apiServer.httpServer.Handler.ServeHTTP(w, r)
+	th.ServeHTTP(w, r)
 
 	assert.Equal(http.StatusBadRequest, w.Code)
 }
@@ -114,57 +255,454 @@ func TestGetLogs(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
 
-	mockCtrl := gomock.NewController(t)
-	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
-	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
-
-	appLogger := slog.Default()
-	handler := NewHandler(mockFileTracker, mockCmdExecutor)
-	router := handler.RegisterRoutes()
-	apiServer := NewServer(":9000", appLogger, router)
+	th := newTestHandler(t)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/v1/logs", nil)
 	r.Header.Set("Content-Type", "application/json")
 
-	mockFileTracker.EXPECT().GetLogs(gomock.Any(), gomock.Any(), gomock.Any()).Return([]mongolog.LogEntry{
-		{
-			ID: uuid.NewString(),
-			Details: map[string]string{
-				"target_path": "test/test.txt",
-				"time":        strconv.FormatInt(time.Now().Unix(), 10),
+	th.tracker.EXPECT().GetLogs(gomock.Any(), mongolog.LogQuery{Limit: 10}).Return(mongolog.LogPage{
+		Items: []mongolog.LogEntry{
+			{
+				ID: uuid.NewString(),
+				Details: map[string]string{
+					"target_path": "test/test.txt",
+					"time":        strconv.FormatInt(time.Now().Unix(), 10),
+				},
 			},
 		},
+		NextCursor:     "next",
+		Total:          25,
+		TotalEstimated: true,
 	}, nil).Times(1)
 
-	apiServer.httpServer.Handler.ServeHTTP(w, r)
+	th.ServeHTTP(w, r)
 
 	assert.Equal(http.StatusOK, w.Code)
 
-	res := []map[string]interface{}{}
+	res := LogsResponse{}
 	err := json.Unmarshal(w.Body.Bytes(), &res)
 	require.NoError(err)
-	assert.Len(res, 1)
+	assert.Len(res.Items, 1)
+	assert.Equal("next", res.NextCursor)
+	assert.Equal(int64(25), res.Total)
+	assert.True(res.TotalEstimated)
+	assert.Equal(int64(10), res.Limit)
+	require.NotNil(res.NextOffset)
+	assert.Equal(int64(1), *res.NextOffset)
+	assert.Equal(LogFilters{}, res.Filters)
+}
+
+// go test -v -cover -run TestGetLogs_Filters ./pkg/httpserver
+func TestGetLogs_Filters(t *testing.T) {
+	sha := strings.Repeat("ab", 32)
+	tests := []struct {
+		name           string
+		url            string
+		expectedFilter *mongolog.LogFilter
+		expectedCode   int
+	}{
+		{
+			"All filters",
+			"/v1/logs?path_prefix=/tmp/dl/&path_glob=**/*.pdf&action=created,DELETED&action=updated&since=1700000000&until=2023-11-15T00:00:00Z&sha256=" + strings.ToUpper(sha),
+			&mongolog.LogFilter{
+				PathPrefix: "/tmp/dl/",
+				PathGlob:   "**/*.pdf",
+				Actions:    []string{"CREATED", "DELETED", "UPDATED"},
+				Since:      time.Unix(1700000000, 0),
+				Until:      time.Date(2023, 11, 15, 0, 0, 0, 0, time.UTC),
+				SHA256:     sha,
+			},
+			http.StatusOK,
+		},
+		{"Unknown action", "/v1/logs?action=RENAMED", nil, http.StatusBadRequest},
+		{"Invalid glob", "/v1/logs?path_glob=[", nil, http.StatusBadRequest},
+		{"Invalid since", "/v1/logs?since=yesterday", nil, http.StatusBadRequest},
+		{"Since after until", "/v1/logs?since=1700000100&until=1700000000", nil, http.StatusBadRequest},
+		{"Invalid md5", "/v1/logs?md5=xyz", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			if tt.expectedFilter != nil {
+				th.tracker.EXPECT().GetLogs(gomock.Any(), mongolog.LogQuery{Filter: *tt.expectedFilter, Limit: 10}).Return(mongolog.LogPage{}, nil).Times(1)
+			}
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedFilter == nil {
+				return
+			}
+
+			res := LogsResponse{}
+			err := json.Unmarshal(w.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Empty(t, res.Items)
+			assert.Nil(t, res.NextOffset)
+			assert.Equal(t, tt.expectedFilter.Actions, res.Filters.Actions)
+			assert.Equal(t, tt.expectedFilter.PathGlob, res.Filters.PathGlob)
+			require.NotNil(t, res.Filters.Since)
+			assert.True(t, tt.expectedFilter.Since.Equal(*res.Filters.Since))
+		})
+	}
+}
+
+// go test -v -cover -run TestGetLogs_Cursor ./pkg/httpserver
+func TestGetLogs_Cursor(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		trackerErr   error
+		expectedCode int
+	}{
+		{"Next page", "/v1/logs?cursor=abc", nil, http.StatusOK},
+		{"Invalid cursor", "/v1/logs?cursor=abc", mongolog.ErrInvalidCursor, http.StatusBadRequest},
+		{"Cursor with offset", "/v1/logs?cursor=abc&offset=10", nil, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			th.tracker.EXPECT().GetLogs(gomock.Any(), mongolog.LogQuery{Limit: 10, Cursor: "abc"}).Return(mongolog.LogPage{}, tt.trackerErr).MaxTimes(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+// go test -v -cover -run TestLogs ./pkg/httpserver
+func TestLogs(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           LogsRequest
+		expectedQuery *mongolog.LogQuery
+		expectedErr   string
+	}{
+		{"Default limit", LogsRequest{}, &mongolog.LogQuery{Limit: 10}, ""},
+		{"Filter", LogsRequest{Limit: 5, Filter: LogFilterRequest{Actions: []string{"created"}}}, &mongolog.LogQuery{Limit: 5, Filter: mongolog.LogFilter{Actions: []string{"CREATED"}}}, ""},
+		{"Negative limit", LogsRequest{Limit: -1}, nil, "limit field cannot be less than 1"},
+		{"Cursor with offset", LogsRequest{Cursor: "abc", Offset: 10}, nil, "cursor and offset fields cannot be combined"},
+		{"Invalid since", LogsRequest{Filter: LogFilterRequest{Since: "yesterday"}}, nil, "since field: expected RFC 3339 or unix seconds"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			if tt.expectedQuery != nil {
+				th.tracker.EXPECT().GetLogs(gomock.Any(), *tt.expectedQuery).Return(mongolog.LogPage{Total: 1}, nil)
+			}
+
+			res, err := th.handler.Logs(context.Background(), tt.req)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), res.Total)
+			assert.Equal(t, []mongolog.LogEntry{}, res.Items)
+		})
+	}
 }
 
 // go test -v -cover -run TestNotFound ./pkg/httpserver
 func TestNotFound(t *testing.T) {
 	assert := assert.New(t)
 
-	mockCtrl := gomock.NewController(t)
-	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
-	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
-
-	appLogger := slog.Default()
-	handler := NewHandler(mockFileTracker, mockCmdExecutor)
-	router := handler.RegisterRoutes()
-	apiServer := NewServer(":9000", appLogger, router)
+	th := newTestHandler(t)
 
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/v1/log", nil)
 	r.Header.Set("Content-Type", "application/json")
 
-	apiServer.httpServer.Handler.ServeHTTP(w, r)
+	th.ServeHTTP(w, r)
 
 	assert.Equal(http.StatusNotFound, w.Code)
 }
+
+// go test -v -cover -run TestListWatches ./pkg/httpserver
+func TestListWatches(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	th := newTestHandler(t)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/watches", nil)
+
+	th.tracker.EXPECT().ListWatches().Return([]config.WatchConfig{
+		{Path: "/tmp/downloads", Recursive: true},
+	}).Times(1)
+
+	th.ServeHTTP(w, r)
+
+	assert.Equal(http.StatusOK, w.Code)
+
+	res := WatchesResponse{}
+	err := json.Unmarshal(w.Body.Bytes(), &res)
+	require.NoError(err)
+	require.Len(res.Watches, 1)
+	assert.Equal("/tmp/downloads", res.Watches[0].Path)
+}
+
+// go test -v -cover -run TestAddWatch ./pkg/httpserver
+func TestAddWatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		trackerErr   error
+		expectedCode int
+	}{
+		{"Watch added", `{"path":"/tmp/documents","include":["*.pdf"]}`, nil, http.StatusCreated},
+		{"Invalid watch", `{"path":"relative dir"}`, filechangestracker.ErrInvalidWatch, http.StatusBadRequest},
+		{"Already watched", `{"path":"/tmp/documents"}`, filechangestracker.ErrWatchExists, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/watches", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+
+			th.tracker.EXPECT().AddWatch(gomock.Any()).DoAndReturn(func(w config.WatchConfig) (config.WatchConfig, error) {
+				assert.True(t, w.Recursive) // recursive defaults to true when left out
+				return w, tt.trackerErr
+			}).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+// go test -v -cover -run TestRemoveWatch ./pkg/httpserver
+func TestRemoveWatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		trackerErr   error
+		expectedCode int
+	}{
+		{"Watch removed", "/v1/watches?path=/tmp/documents", nil, http.StatusOK},
+		{"Not watched", "/v1/watches?path=/tmp/other", filechangestracker.ErrWatchNotFound, http.StatusNotFound},
+		{"Invalid path", "/v1/watches?path=relative/dir", filechangestracker.ErrInvalidWatch, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodDelete, tt.url, nil)
+
+			th.tracker.EXPECT().RemoveWatch(gomock.Any()).Return(tt.trackerErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+// go test -v -cover -run TestScan ./pkg/httpserver
+func TestScan(t *testing.T) {
+	status := filechangestracker.ScanStatus{ID: uuid.NewString(), State: filechangestracker.ScanRunning}
+
+	tests := []struct {
+		name         string
+		trackerErr   error
+		expectedCode int
+	}{
+		{"Scan started", nil, http.StatusAccepted},
+		{"Scan running", filechangestracker.ErrScanInProgress, http.StatusConflict},
+		{"Scan failed", errors.New("tracker stopped"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/scans", nil)
+
+			th.tracker.EXPECT().StartScan().Return(status, tt.trackerErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.trackerErr == nil {
+				var res filechangestracker.ScanStatus
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				assert.NoError(t, err)
+				assert.Equal(t, status.ID, res.ID)
+				assert.Equal(t, "/v1/scans/"+status.ID, w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+// go test -v -cover -run TestGetScan ./pkg/httpserver
+func TestGetScan(t *testing.T) {
+	status := filechangestracker.ScanStatus{
+		ID:     uuid.NewString(),
+		State:  filechangestracker.ScanSucceeded,
+		Result: &filechangestracker.ScanResult{Files: 3, Updated: 1},
+	}
+
+	tests := []struct {
+		name         string
+		trackerErr   error
+		expectedCode int
+	}{
+		{"Scan found", nil, http.StatusOK},
+		{"Scan not found", filechangestracker.ErrScanNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/v1/scans/"+status.ID, nil)
+
+			th.tracker.EXPECT().GetScan(status.ID).Return(status, tt.trackerErr).Times(1)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.trackerErr == nil {
+				var res filechangestracker.ScanStatus
+				err := json.Unmarshal(w.Body.Bytes(), &res)
+				assert.NoError(t, err)
+				require.NotNil(t, res.Result)
+				assert.Equal(t, 3, res.Result.Files)
+				assert.Equal(t, 1, res.Result.Updated)
+			}
+		})
+	}
+}
+
+// go test -v -cover -run TestListFileVersions ./pkg/httpserver
+func TestListFileVersions(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		disabled     bool
+		expectedCode int
+	}{
+		{"Versions listed", "/v1/files/versions?path=/tmp/documents/a.txt", false, http.StatusOK},
+		{"Missing path", "/v1/files/versions", false, http.StatusBadRequest},
+		{"Snapshots disabled", "/v1/files/versions?path=/tmp/documents/a.txt", true, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+			if tt.disabled {
+				th.handler.snapshots = nil
+			}
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			if tt.expectedCode == http.StatusOK {
+				th.snapshots.EXPECT().Versions("/tmp/documents/a.txt").Return([]snapshot.Version{{ID: "v2"}, {ID: "v1"}}, nil).Times(1)
+			}
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				var res FileVersionsResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				require.Len(t, res.Versions, 2)
+				assert.Equal(t, "v2", res.Versions[0].ID)
+			}
+		})
+	}
+}
+
+// go test -v -cover -run TestFileDiff ./pkg/httpserver
+func TestFileDiff(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		diffErr      error
+		expectedCode int
+	}{
+		{"Diff returned", "/v1/files/diff?path=/tmp/documents/a.txt&from=v1&to=v2", nil, http.StatusOK},
+		{"Missing from", "/v1/files/diff?path=/tmp/documents/a.txt", nil, http.StatusBadRequest},
+		{"Unknown version", "/v1/files/diff?path=/tmp/documents/a.txt&from=v9", snapshot.ErrVersionNotFound, http.StatusNotFound},
+		{"Binary file", "/v1/files/diff?path=/tmp/documents/a.png&from=v1", snapshot.ErrNotText, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			if strings.Contains(tt.url, "from=") {
+				th.snapshots.EXPECT().Diff(gomock.Any(), gomock.Any(), gomock.Any()).Return("-a\n+b\n", tt.diffErr).Times(1)
+			}
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				var res FileDiffResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+				assert.Equal(t, "-a\n+b\n", res.Diff)
+				assert.Equal(t, "v2", res.To)
+			}
+		})
+	}
+}
+
+// go test -v -cover -run TestRestoreFile ./pkg/httpserver
+func TestRestoreFile(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		restoreErr   error
+		expectedCode int
+	}{
+		{"Version restored", `{"path":"/tmp/documents/a.txt","version_id":"v1"}`, nil, http.StatusOK},
+		{"Missing version", `{"path":"/tmp/documents/a.txt"}`, nil, http.StatusBadRequest},
+		{"Unknown version", `{"path":"/tmp/documents/a.txt","version_id":"v9"}`, snapshot.ErrVersionNotFound, http.StatusNotFound},
+		{"Path rejected", `{"path":"/etc/passwd","version_id":"v1"}`, commandexecutor.ErrCommandRejected, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/v1/files/restore", strings.NewReader(tt.body))
+
+			if strings.Contains(tt.body, "version_id") {
+				th.snapshots.EXPECT().Restore(gomock.Any(), gomock.Any()).Return(snapshot.Version{ID: "v1"}, tt.restoreErr).Times(1)
+			}
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
@@ -2,24 +2,36 @@ package httpserver
 
 import (
 	"github.com/danielboakye/filechangestracker/internal/commandexecutor"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
 	"github.com/danielboakye/filechangestracker/internal/filechangestracker"
+	"github.com/danielboakye/filechangestracker/internal/reporter"
+	"github.com/danielboakye/filechangestracker/internal/snapshot"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/cors"
 )
 
 type Handler struct {
-	tracker  filechangestracker.FileChangesTracker
-	executor commandexecutor.CommandExecutor
+	tracker   filechangestracker.FileChangesTracker
+	executor  commandexecutor.CommandExecutor
+	reporter  reporter.Reporter
+	events    eventbus.Bus
+	snapshots snapshot.Store // nil when snapshots are disabled
 }
 
 func NewHandler(
 	tracker filechangestracker.FileChangesTracker,
 	executor commandexecutor.CommandExecutor,
+	reporter reporter.Reporter,
+	events eventbus.Bus,
+	snapshots snapshot.Store,
 ) *Handler {
 	return &Handler{
-		tracker:  tracker,
-		executor: executor,
+		tracker:   tracker,
+		executor:  executor,
+		reporter:  reporter,
+		events:    events,
+		snapshots: snapshots,
 	}
 }
 
@@ -30,17 +42,32 @@ func (h *Handler) RegisterRoutes() *chi.Mux {
 
 	router.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID"},
+		ExposedHeaders:   []string{"Link", "Location"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
 	router.Route("/v1", func(r chi.Router) {
 		r.Post("/commands", h.HandleSubmitCommands)
+		r.Get("/commands/{id}", h.HandleGetCommand)
+		r.Delete("/commands/{id}", h.HandleCancelCommand)
 		r.Get("/health", h.HandleHealthCheck)
 		r.Get("/logs", h.HandleGetLogs)
+		r.Get("/events/stream", h.HandleEventStream)
+		r.Get("/ws", h.HandleWebSocket)
+
+		r.Get("/watches", h.HandleListWatches)
+		r.Post("/watches", h.HandleAddWatch)
+		r.Delete("/watches", h.HandleRemoveWatch)
+
+		r.Post("/scans", h.HandleScan)
+		r.Get("/scans/{id}", h.HandleGetScan)
+
+		r.Get("/files/versions", h.HandleListFileVersions)
+		r.Get("/files/diff", h.HandleFileDiff)
+		r.Post("/files/restore", h.HandleRestoreFile)
 	})
 
 	router.NotFound(h.NotFoundHandler)
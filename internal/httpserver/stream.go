@@ -0,0 +1,128 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/danielboakye/filechangestracker/pkg/response"
+)
+
+const (
+	// streamReplayBatch is how many missed logs are read from the store at a
+	// time when a client resumes with Last-Event-ID
+	streamReplayBatch = 500
+	// streamKeepAlive keeps proxies from closing an idle stream
+	streamKeepAlive = 15 * time.Second
+)
+
+// HandleEventStream streams file changes as server-sent events. It accepts
+// the filters of GET /v1/logs, and a client reconnecting with Last-Event-ID
+// first receives the changes it missed from the log store.
+func (h *Handler) HandleEventStream(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseLogFilter(r.URL.Query())
+	if err != nil {
+		response.InvalidRequest(w, err.Error())
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID != "" {
+		err = mongolog.ValidateCursor(lastEventID)
+		if err != nil {
+			response.InvalidRequest(w, "invalid Last-Event-ID")
+			return
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		response.InternalError(w)
+		return
+	}
+
+	// subscribe before replaying so nothing written meanwhile is missed
+	match := filter.Matcher()
+	sub := h.events.Subscribe(func(event eventbus.Event) bool {
+		entry, ok := event.Data.(mongolog.LogEntry)
+		return event.Topic == eventbus.TopicFileChange && ok && match(entry)
+	})
+	defer h.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// cursor ends at the newest event the client has, live events up to it
+	// were written before the replay read them
+	cursor := lastEventID
+	for cursor != "" {
+		entries, err := h.tracker.GetLogsAfter(r.Context(), mongolog.LogQuery{
+			Filter: filter,
+			Limit:  streamReplayBatch,
+			Cursor: cursor,
+		})
+		if err != nil {
+			writeEvent(w, "", "error", response.ErrorMessage{Message: "error replaying missed events"})
+			flusher.Flush()
+			return
+		}
+
+		for _, entry := range entries {
+			writeEvent(w, entry.Cursor(), eventbus.TopicFileChange, entry)
+			cursor = entry.Cursor()
+		}
+		flusher.Flush()
+
+		if len(entries) < streamReplayBatch {
+			break
+		}
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case event, ok := <-sub.Events():
+			if !ok {
+				// evicted for falling behind, the client reconnects and
+				// catches up from the store with Last-Event-ID
+				writeEvent(w, "", "evicted", response.ErrorMessage{Message: "stream fell behind, reconnect to resume"})
+				flusher.Flush()
+				return
+			}
+			if cursor != "" && !event.Data.(mongolog.LogEntry).After(cursor) {
+				continue
+			}
+
+			writeEvent(w, event.ID, event.Topic, event.Data)
+			flusher.Flush()
+		}
+	}
+}
+
+// writeEvent writes a server-sent event, an empty id leaves the client's
+// last event id unchanged
+func writeEvent(w io.Writer, id, event string, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+}
@@ -0,0 +1,120 @@
+package httpserver
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	commandexecutormock "github.com/danielboakye/filechangestracker/mocks/commandexecutor"
+	filechangestrackermock "github.com/danielboakye/filechangestracker/mocks/filechangestracker"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestEventStream ./internal/httpserver
+func TestEventStream(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
+	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	appLogger := slog.Default()
+	events := eventbus.New(appLogger)
+	handler := NewHandler(mockFileTracker, mockCmdExecutor, mockReporter, events, nil)
+	server := httptest.NewServer(handler.RegisterRoutes())
+	defer server.Close()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	newEntry := func(id, action string) mongolog.LogEntry {
+		return mongolog.LogEntry{
+			ID:        id,
+			CreatedAt: now,
+			Details:   map[string]string{"target_path": "/tmp/dl/" + id, "action": action},
+		}
+	}
+	last := newEntry("a", "CREATED")
+	missed := newEntry("b", "CREATED")
+
+	mockFileTracker.EXPECT().GetLogsAfter(gomock.Any(), mongolog.LogQuery{
+		Filter: mongolog.LogFilter{Actions: []string{"CREATED"}},
+		Limit:  streamReplayBatch,
+		Cursor: last.Cursor(),
+	}).Return([]mongolog.LogEntry{missed}, nil).Times(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/v1/events/stream?action=CREATED", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", last.Cursor())
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(res.Body)
+	nextEvent := func() (id, data string) {
+		for lines.Scan() {
+			line := lines.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			case line == "" && data != "":
+				return id, data
+			}
+		}
+		return id, data
+	}
+
+	id, data := nextEvent()
+	assert.Equal(t, missed.Cursor(), id)
+	assert.Contains(t, data, `"id":"b"`)
+
+	// subscribed before the replay, so live events are not lost
+	events.Publish(eventbus.Event{Topic: eventbus.TopicFileChange, ID: last.Cursor(), Data: last})     // the client has it
+	events.Publish(eventbus.Event{Topic: eventbus.TopicFileChange, ID: missed.Cursor(), Data: missed}) // already replayed
+	events.Publish(eventbus.Event{Topic: eventbus.TopicFileChange, ID: "c", Data: newEntry("c", "DELETED")})
+	events.Publish(eventbus.Event{Topic: eventbus.TopicFileChange, ID: "d", Data: newEntry("d", "CREATED")})
+
+	id, data = nextEvent()
+	assert.Equal(t, "d", id)
+	assert.Contains(t, data, `"id":"d"`)
+}
+
+// go test -v -cover -run TestEventStream_InvalidRequest ./internal/httpserver
+func TestEventStream_InvalidRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		lastEventID string
+	}{
+		{"Invalid filter", "/v1/events/stream?action=RENAMED", ""},
+		{"Invalid Last-Event-ID", "/v1/events/stream", "not-a-cursor"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newTestHandler(t)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			r.Header.Set("Last-Event-ID", tt.lastEventID)
+
+			th.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+		})
+	}
+}
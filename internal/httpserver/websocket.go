@@ -0,0 +1,226 @@
+package httpserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2 * wsPingInterval
+	wsWriteWait    = 10 * time.Second
+	wsMaxMessage   = 4096
+)
+
+// wsAllowedOrigins are the origins of the Wails UI, wails://wails on macOS
+// and Linux and http://wails.localhost on Windows
+var wsAllowedOrigins = map[string]bool{
+	"wails://wails":          true,
+	"http://wails.localhost": true,
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: checkWSOrigin,
+}
+
+// checkWSOrigin accepts clients without an Origin, which are not browsers,
+// pages served by the api's own host and the Wails UI. Any other page could
+// otherwise read the file changes through the visitor's browser.
+func checkWSOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if wsAllowedOrigins[strings.ToLower(origin)] {
+		return true
+	}
+
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// WSRequest is a message sent by a websocket client. File changes are
+// subscribed to per path, which also covers everything below it. Job updates
+// are subscribed to as a whole.
+type WSRequest struct {
+	Type  string `json:"type"`  // subscribe or unsubscribe
+	Topic string `json:"topic"` // file_change or job
+	Path  string `json:"path,omitempty"`
+}
+
+// WSMessage is a message sent to a websocket client, Type is subscribed,
+// unsubscribed, event or error
+type WSMessage struct {
+	Type    string      `json:"type"`
+	Topic   string      `json:"topic,omitempty"`
+	Path    string      `json:"path,omitempty"`
+	ID      string      `json:"id,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+	Message string      `json:"message,omitempty"`
+}
+
+// wsSubscriptions is what a websocket client is subscribed to, it is read
+// by the event bus and written by the client's read loop
+type wsSubscriptions struct {
+	mu    sync.RWMutex
+	paths map[string]bool
+	jobs  bool
+}
+
+func (s *wsSubscriptions) match(event eventbus.Event) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch event.Topic {
+	case eventbus.TopicJob:
+		return s.jobs
+	case eventbus.TopicFileChange:
+		entry, ok := event.Data.(mongolog.LogEntry)
+		if !ok {
+			return false
+		}
+		targetPath := entry.Details["target_path"]
+		for path := range s.paths {
+			if targetPath == path || strings.HasPrefix(targetPath, strings.TrimSuffix(path, "/")+"/") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// apply updates the subscriptions and returns the reply to req
+func (s *wsSubscriptions) apply(req WSRequest) WSMessage {
+	subscribe := req.Type == "subscribe"
+	if !subscribe && req.Type != "unsubscribe" {
+		return WSMessage{Type: "error", Message: "type must be subscribe or unsubscribe"}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Topic {
+	case eventbus.TopicJob:
+		s.jobs = subscribe
+		return WSMessage{Type: req.Type + "d", Topic: req.Topic}
+	case eventbus.TopicFileChange:
+		if !filepath.IsAbs(req.Path) {
+			return WSMessage{Type: "error", Topic: req.Topic, Path: req.Path, Message: "path must be absolute"}
+		}
+		path := filepath.Clean(req.Path)
+		if subscribe {
+			s.paths[path] = true
+		} else {
+			delete(s.paths, path)
+		}
+		return WSMessage{Type: req.Type + "d", Topic: req.Topic, Path: path}
+	default:
+		return WSMessage{Type: "error", Topic: req.Topic, Message: "topic must be file_change or job"}
+	}
+}
+
+// HandleWebSocket upgrades to a websocket that sends the file changes and
+// job updates the client subscribed to with WSRequest messages
+func (h *Handler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return // the upgrader already replied
+	}
+	defer conn.Close()
+
+	subs := &wsSubscriptions{paths: make(map[string]bool)}
+	sub := h.events.Subscribe(subs.match)
+	defer h.events.Unsubscribe(sub)
+
+	// the read loop hands replies to this goroutine, the only writer
+	replies := make(chan WSMessage, 16)
+	done := make(chan struct{})
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		defer close(done)
+		readWebSocket(conn, subs, replies, stop)
+	}()
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+
+	for {
+		var msg WSMessage
+		select {
+		case <-r.Context().Done():
+			writeClose(conn, websocket.CloseGoingAway, "server shutting down")
+			return
+		case <-done:
+			return
+		case <-ping.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			if err != nil {
+				return
+			}
+			continue
+		case msg = <-replies:
+		case event, ok := <-sub.Events():
+			if !ok {
+				writeClose(conn, websocket.CloseTryAgainLater, "fell behind the event feed")
+				return
+			}
+			msg = WSMessage{Type: "event", Topic: event.Topic, ID: event.ID, Data: event.Data}
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		err := conn.WriteJSON(msg)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readWebSocket applies the client's requests until the connection fails
+// or stop is closed
+func readWebSocket(conn *websocket.Conn, subs *wsSubscriptions, replies chan<- WSMessage, stop <-chan struct{}) {
+	conn.SetReadLimit(wsMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var reply WSMessage
+		var req WSRequest
+		err = json.Unmarshal(data, &req)
+		if err != nil {
+			reply = WSMessage{Type: "error", Message: "invalid message: " + err.Error()}
+		} else {
+			reply = subs.apply(req)
+		}
+
+		select {
+		case replies <- reply:
+		case <-stop:
+			return
+		}
+	}
+}
+
+func writeClose(conn *websocket.Conn, code int, reason string) {
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(wsWriteWait))
+}
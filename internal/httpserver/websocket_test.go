@@ -0,0 +1,129 @@
+package httpserver
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/commandexecutor"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	commandexecutormock "github.com/danielboakye/filechangestracker/mocks/commandexecutor"
+	filechangestrackermock "github.com/danielboakye/filechangestracker/mocks/filechangestracker"
+	reportermock "github.com/danielboakye/filechangestracker/mocks/reporter"
+	"github.com/golang/mock/gomock"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestWebSocket ./internal/httpserver
+func TestWebSocket(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	mockCmdExecutor := commandexecutormock.NewMockCommandExecutor(mockCtrl)
+	mockFileTracker := filechangestrackermock.NewMockFileChangesTracker(mockCtrl)
+	mockReporter := reportermock.NewMockReporter(mockCtrl)
+
+	appLogger := slog.Default()
+	events := eventbus.New(appLogger)
+	handler := NewHandler(mockFileTracker, mockCmdExecutor, mockReporter, events, nil)
+	server := httptest.NewServer(handler.RegisterRoutes())
+	defer server.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	request := func(req WSRequest) WSMessage {
+		require.NoError(t, conn.WriteJSON(req))
+		return readMessage(t, conn)
+	}
+	fileChange := func(path string) eventbus.Event {
+		entry := mongolog.LogEntry{ID: path, Details: map[string]string{"target_path": path}}
+		return eventbus.Event{Topic: eventbus.TopicFileChange, ID: path, Data: entry}
+	}
+
+	reply := request(WSRequest{Type: "subscribe", Topic: eventbus.TopicFileChange, Path: "/tmp/dl/"})
+	assert.Equal(t, WSMessage{Type: "subscribed", Topic: eventbus.TopicFileChange, Path: "/tmp/dl"}, reply)
+
+	reply = request(WSRequest{Type: "subscribe", Topic: eventbus.TopicFileChange, Path: "relative"})
+	assert.Equal(t, "error", reply.Type)
+	reply = request(WSRequest{Type: "watch", Topic: eventbus.TopicJob})
+	assert.Equal(t, "error", reply.Type)
+
+	events.Publish(fileChange("/tmp/dlx/a.txt")) // not below /tmp/dl
+	events.Publish(eventbus.Event{Topic: eventbus.TopicJob, ID: "job-1", Data: commandexecutor.Job{ID: "job-1"}})
+	events.Publish(fileChange("/tmp/dl/b.txt"))
+
+	msg := readMessage(t, conn)
+	assert.Equal(t, "event", msg.Type)
+	assert.Equal(t, "/tmp/dl/b.txt", msg.ID)
+
+	reply = request(WSRequest{Type: "subscribe", Topic: eventbus.TopicJob})
+	assert.Equal(t, "subscribed", reply.Type)
+	reply = request(WSRequest{Type: "unsubscribe", Topic: eventbus.TopicFileChange, Path: "/tmp/dl"})
+	assert.Equal(t, "unsubscribed", reply.Type)
+
+	events.Publish(fileChange("/tmp/dl/c.txt"))
+	events.Publish(eventbus.Event{Topic: eventbus.TopicJob, ID: "job-2", Data: commandexecutor.Job{ID: "job-2"}})
+
+	msg = readMessage(t, conn)
+	assert.Equal(t, eventbus.TopicJob, msg.Topic)
+	assert.Equal(t, "job-2", msg.ID)
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) WSMessage {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	var msg WSMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+// go test -v -cover -run TestWebSocket_Origin ./internal/httpserver
+func TestWebSocket_Origin(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	handler := NewHandler(
+		filechangestrackermock.NewMockFileChangesTracker(mockCtrl),
+		commandexecutormock.NewMockCommandExecutor(mockCtrl),
+		reportermock.NewMockReporter(mockCtrl),
+		eventbus.New(slog.Default()),
+		nil,
+	)
+	server := httptest.NewServer(handler.RegisterRoutes())
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		origin       string
+		expectedCode int
+	}{
+		{"No origin", "", http.StatusSwitchingProtocols},
+		{"Same origin", server.URL, http.StatusSwitchingProtocols},
+		{"Wails UI", "wails://wails", http.StatusSwitchingProtocols},
+		{"Foreign origin", "https://evil.example", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.origin != "" {
+				header.Set("Origin", tt.origin)
+			}
+
+			conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/v1/ws", header)
+			if conn != nil {
+				conn.Close()
+			}
+			if tt.expectedCode != http.StatusSwitchingProtocols {
+				assert.Error(t, err)
+			}
+			require.NotNil(t, resp)
+			assert.Equal(t, tt.expectedCode, resp.StatusCode)
+		})
+	}
+}
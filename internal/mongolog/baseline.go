@@ -0,0 +1,64 @@
+package mongolog
+
+import (
+	"time"
+)
+
+// FileState is what a baseline scan records about a file
+type FileState struct {
+	Watch  string `bson:"watch" json:"watch"` // root of the watch the file was found under
+	Size   int64  `bson:"size" json:"size"`
+	Mode   string `bson:"mode" json:"mode"`
+	UID    string `bson:"uid,omitempty" json:"uid,omitempty"`
+	GID    string `bson:"gid,omitempty" json:"gid,omitempty"`
+	MTime  int64  `bson:"mtime" json:"mtime"`
+	SHA256 string `bson:"sha256,omitempty" json:"sha256,omitempty"` // empty for files over hashing.max_size
+}
+
+// Baseline is the state of the watched files at the last scan
+type Baseline struct {
+	Files     map[string]FileState // by path
+	Roots     []string             // watches that were scanned
+	ScannedAt time.Time
+}
+
+// BaselineUpdate is what a scan changed in the baseline, only that is
+// written. Replace drops the stored files first, for a first scan and after
+// an update failed.
+type BaselineUpdate struct {
+	Replace   bool                 `json:"replace,omitempty"`
+	Set       map[string]FileState `json:"set,omitempty"` // new and changed files, by path
+	Deleted   []string             `json:"deleted,omitempty"`
+	Roots     []string             `json:"roots"`
+	ScannedAt time.Time            `json:"scanned_at"`
+}
+
+// apply applies update to b, for the stores that keep the baseline in
+// memory
+func (b *Baseline) apply(update BaselineUpdate) {
+	if update.Replace || b.Files == nil {
+		b.Files = make(map[string]FileState, len(update.Set))
+	}
+	for path, state := range update.Set {
+		b.Files[path] = state
+	}
+	for _, path := range update.Deleted {
+		delete(b.Files, path)
+	}
+	b.Roots = update.Roots
+	b.ScannedAt = update.ScannedAt
+}
+
+// copy returns b with its own map, the caller may change it
+func (b *Baseline) copy() Baseline {
+	files := make(map[string]FileState, len(b.Files))
+	for path, state := range b.Files {
+		files[path] = state
+	}
+
+	return Baseline{
+		Files:     files,
+		Roots:     append([]string(nil), b.Roots...),
+		ScannedAt: b.ScannedAt,
+	}
+}
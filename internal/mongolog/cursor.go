@@ -0,0 +1,101 @@
+package mongolog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// logCursor is the sort key of the last entry of a page, the next page
+// starts right after it. Clients only see it as an opaque token.
+type logCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+}
+
+// Cursor is the position of the entry, reading after it continues with the
+// entries that were written later
+func (e LogEntry) Cursor() string {
+	data, _ := json.Marshal(logCursor{CreatedAt: e.CreatedAt, ID: e.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// After reports whether e sorts after the position of cursor, which is what
+// reading after cursor returns. Every entry is after an invalid cursor.
+func (e LogEntry) After(cursor string) bool {
+	c, err := decodeCursor(cursor)
+	if err != nil {
+		return true
+	}
+
+	return c.compare(e) > 0
+}
+
+// ValidateCursor checks token can be used as LogQuery.Cursor
+func ValidateCursor(token string) error {
+	_, err := decodeCursor(token)
+	return err
+}
+
+func decodeCursor(token string) (logCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return logCursor{}, ErrInvalidCursor
+	}
+
+	var c logCursor
+	err = json.Unmarshal(data, &c)
+	if err != nil || c.CreatedAt.IsZero() || c.ID == "" {
+		return logCursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+// older matches the entries sorted before c in (created_at, _id) order
+func (c logCursor) older() bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "created_at", Value: bson.D{{Key: "$lt", Value: c.CreatedAt}}}},
+		bson.D{
+			{Key: "created_at", Value: c.CreatedAt},
+			{Key: "_id", Value: bson.D{{Key: "$lt", Value: c.ID}}},
+		},
+	}}}
+}
+
+// newer matches the entries sorted after c in (created_at, _id) order
+func (c logCursor) newer() bson.D {
+	return bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "created_at", Value: bson.D{{Key: "$gt", Value: c.CreatedAt}}}},
+		bson.D{
+			{Key: "created_at", Value: c.CreatedAt},
+			{Key: "_id", Value: bson.D{{Key: "$gt", Value: c.ID}}},
+		},
+	}}}
+}
+
+// compare orders e against the position of c in (created_at, _id) order,
+// it is negative when e sorts before c
+func (c logCursor) compare(e LogEntry) int {
+	switch {
+	case e.CreatedAt.Before(c.CreatedAt):
+		return -1
+	case e.CreatedAt.After(c.CreatedAt):
+		return 1
+	}
+	return strings.Compare(e.ID, c.ID)
+}
+
+// sortOldestFirst sorts entries in (created_at, _id) order
+func sortOldestFirst(entries []LogEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return logCursor{CreatedAt: entries[j].CreatedAt, ID: entries[j].ID}.compare(entries[i]) < 0
+	})
+}
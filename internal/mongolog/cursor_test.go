@@ -0,0 +1,32 @@
+package mongolog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover -run TestCursor ./internal/mongolog
+func TestCursor(t *testing.T) {
+	entry := LogEntry{ID: "b6c1c0f4", CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 123000000, time.UTC)}
+
+	token := entry.Cursor()
+	c, err := decodeCursor(token)
+	require.NoError(t, err)
+	assert.Equal(t, entry.ID, c.ID)
+	assert.True(t, entry.CreatedAt.Equal(c.CreatedAt))
+
+	later := LogEntry{ID: "a", CreatedAt: entry.CreatedAt.Add(time.Millisecond)}
+	sameTime := LogEntry{ID: "c", CreatedAt: entry.CreatedAt}
+	assert.True(t, later.After(token))
+	assert.True(t, sameTime.After(token))
+	assert.False(t, entry.After(token))
+	assert.False(t, LogEntry{ID: "a", CreatedAt: entry.CreatedAt}.After(token))
+
+	for _, invalid := range []string{"not base64!", "bm90IGpzb24", "e30"} { // "not json", "{}"
+		_, err = decodeCursor(invalid)
+		assert.ErrorIs(t, err, ErrInvalidCursor, invalid)
+	}
+}
@@ -0,0 +1,187 @@
+package mongolog
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	md5Pattern    = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// LogFilter narrows the logs returned by ReadLogsPaginated, zero fields
+// match every entry. It is never encoded, the logs API echoes it back as
+// httpserver.LogFilters, whose pointer times are left out when unset.
+type LogFilter struct {
+	PathPrefix string
+	PathGlob   string // * and ? stay within a path segment, ** crosses them
+	Actions    []string
+	Since      time.Time // inclusive, on the event time
+	Until      time.Time // exclusive, on the event time
+	MD5        string
+	SHA256     string
+}
+
+// Validate checks and normalizes the filter
+func (f *LogFilter) Validate() error {
+	if f.PathGlob != "" {
+		if _, err := path.Match(f.PathGlob, ""); err != nil {
+			return fmt.Errorf("invalid path_glob: %s", f.PathGlob)
+		}
+	}
+
+	for i, action := range f.Actions {
+		action = strings.ToUpper(strings.TrimSpace(action))
+		if !contains(config.WatchActions, action) {
+			return fmt.Errorf("invalid action: %s, expected one of %s", f.Actions[i], strings.Join(config.WatchActions, ", "))
+		}
+		f.Actions[i] = action
+	}
+
+	if !f.Since.IsZero() && !f.Until.IsZero() && !f.Since.Before(f.Until) {
+		return errors.New("since must be before until")
+	}
+
+	f.MD5 = strings.ToLower(f.MD5)
+	if f.MD5 != "" && !md5Pattern.MatchString(f.MD5) {
+		return errors.New("md5 must be 32 hex characters")
+	}
+	f.SHA256 = strings.ToLower(f.SHA256)
+	if f.SHA256 != "" && !sha256Pattern.MatchString(f.SHA256) {
+		return errors.New("sha256 must be 64 hex characters")
+	}
+
+	return nil
+}
+
+// query translates the filter into a mongo filter on indexed fields
+func (f LogFilter) query() bson.D {
+	filter := bson.D{}
+
+	// anchored regexes without options use the target_path index
+	var pathPatterns []string
+	if f.PathPrefix != "" {
+		pathPatterns = append(pathPatterns, "^"+regexp.QuoteMeta(f.PathPrefix))
+	}
+	if f.PathGlob != "" {
+		pathPatterns = append(pathPatterns, globToRegex(f.PathGlob))
+	}
+	for _, pattern := range pathPatterns {
+		filter = append(filter, bson.E{Key: "details.target_path", Value: primitive.Regex{Pattern: pattern}})
+	}
+	if len(pathPatterns) > 1 {
+		// both conditions on the same key, the second would replace the first
+		filter = bson.D{{Key: "$and", Value: bson.A{bson.D{filter[0]}, bson.D{filter[1]}}}}
+	}
+
+	if len(f.Actions) > 0 {
+		filter = append(filter, bson.E{Key: "details.action", Value: bson.D{{Key: "$in", Value: f.Actions}}})
+	}
+
+	eventTime := bson.D{}
+	if !f.Since.IsZero() {
+		eventTime = append(eventTime, bson.E{Key: "$gte", Value: f.Since})
+	}
+	if !f.Until.IsZero() {
+		eventTime = append(eventTime, bson.E{Key: "$lt", Value: f.Until})
+	}
+	if len(eventTime) > 0 {
+		filter = append(filter, bson.E{Key: "event_time", Value: eventTime})
+	}
+
+	if f.MD5 != "" {
+		filter = append(filter, bson.E{Key: "details.md5", Value: f.MD5})
+	}
+	if f.SHA256 != "" {
+		filter = append(filter, bson.E{Key: "details.sha256", Value: f.SHA256})
+	}
+
+	return filter
+}
+
+// Matcher returns a func reporting whether an entry passes the filter, for
+// entries that are not read from the store
+func (f LogFilter) Matcher() func(entry LogEntry) bool {
+	var glob *regexp.Regexp
+	if f.PathGlob != "" {
+		glob = regexp.MustCompile(globToRegex(f.PathGlob))
+	}
+
+	return func(entry LogEntry) bool {
+		targetPath := entry.Details["target_path"]
+		switch {
+		case f.PathPrefix != "" && !strings.HasPrefix(targetPath, f.PathPrefix):
+			return false
+		case glob != nil && !glob.MatchString(targetPath):
+			return false
+		case len(f.Actions) > 0 && !contains(f.Actions, entry.Details["action"]):
+			return false
+		case !f.Since.IsZero() && entry.EventTime.Before(f.Since):
+			return false
+		case !f.Until.IsZero() && !entry.EventTime.Before(f.Until):
+			return false
+		case f.MD5 != "" && entry.Details["md5"] != f.MD5:
+			return false
+		case f.SHA256 != "" && entry.Details["sha256"] != f.SHA256:
+			return false
+		}
+		return true
+	}
+}
+
+// globToRegex converts a glob into an anchored regex matching whole paths
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+				continue
+			}
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			// path.Match classes, including ^ negation and \ escapes, read
+			// the same as regex classes
+			b.WriteString(glob[i : i+end+1])
+			i += end
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package mongolog
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// go test -v -cover ./internal/mongolog/...
+
+// go test -v -cover -run TestLogFilter_Validate ./internal/mongolog
+func TestLogFilter_Validate(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name          string
+		filter        LogFilter
+		expectedError string
+	}{
+		{"Empty filter", LogFilter{}, ""},
+		{"Lowercase action", LogFilter{Actions: []string{"created"}}, ""},
+		{"Unknown action", LogFilter{Actions: []string{"RENAMED"}}, "invalid action"},
+		{"Invalid glob", LogFilter{PathGlob: "[a-"}, "invalid path_glob"},
+		{"Time range", LogFilter{Since: since, Until: since.Add(time.Hour)}, ""},
+		{"Empty time range", LogFilter{Since: since, Until: since}, "since must be before until"},
+		{"Uppercase md5", LogFilter{MD5: "D41D8CD98F00B204E9800998ECF8427E"}, ""},
+		{"Short md5", LogFilter{MD5: "d41d8cd9"}, "md5 must be 32 hex characters"},
+		{"Non hex sha256", LogFilter{SHA256: "z3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b85"}, "sha256 must be 64 hex characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}
+
+// go test -v -cover -run TestLogFilter_Query ./internal/mongolog
+func TestLogFilter_Query(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		filter   LogFilter
+		expected bson.D
+	}{
+		{"Empty filter", LogFilter{}, bson.D{}},
+		{
+			"Prefix is escaped",
+			LogFilter{PathPrefix: "/tmp/v1.2 (old)/"},
+			bson.D{{Key: "details.target_path", Value: primitive.Regex{Pattern: `^/tmp/v1\.2 \(old\)/`}}},
+		},
+		{
+			"Prefix and glob",
+			LogFilter{PathPrefix: "/tmp/", PathGlob: "/tmp/*.txt"},
+			bson.D{{Key: "$and", Value: bson.A{
+				bson.D{{Key: "details.target_path", Value: primitive.Regex{Pattern: `^/tmp/`}}},
+				bson.D{{Key: "details.target_path", Value: primitive.Regex{Pattern: `^/tmp/[^/]*\.txt$`}}},
+			}}},
+		},
+		{
+			"Actions, time range and hashes",
+			LogFilter{Actions: []string{"CREATED"}, Since: since, MD5: "d41d8cd98f00b204e9800998ecf8427e"},
+			bson.D{
+				{Key: "details.action", Value: bson.D{{Key: "$in", Value: []string{"CREATED"}}}},
+				{Key: "event_time", Value: bson.D{{Key: "$gte", Value: since}}},
+				{Key: "details.md5", Value: "d41d8cd98f00b204e9800998ecf8427e"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.query())
+		})
+	}
+}
+
+// go test -v -cover -run TestGlobToRegex ./internal/mongolog
+func TestGlobToRegex(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{"/tmp/*.txt", "/tmp/a.txt", true},
+		{"/tmp/*.txt", "/tmp/sub/a.txt", false},
+		{"/tmp/**.txt", "/tmp/sub/a.txt", true},
+		{"**/report-?.pdf", "/home/me/report-1.pdf", true},
+		{"**/report-?.pdf", "/home/me/report-10.pdf", false},
+		{"/tmp/[ab].txt", "/tmp/b.txt", true},
+		{"/tmp/[^ab].txt", "/tmp/b.txt", false},
+		{"/tmp/a+b.txt", "/tmp/a+b.txt", true},
+		{"/tmp/a+b.txt", "/tmp/aab.txt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.glob+" "+tt.path, func(t *testing.T) {
+			re, err := regexp.Compile(globToRegex(tt.glob))
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, re.MatchString(tt.path))
+		})
+	}
+}
+
+// go test -v -cover -run TestLogFilter_Matcher ./internal/mongolog
+func TestLogFilter_Matcher(t *testing.T) {
+	since := time.Unix(1700000000, 0)
+	entry := LogEntry{
+		Details: map[string]string{
+			"target_path": "/tmp/dl/report.pdf",
+			"action":      "CREATED",
+			"md5":         "d41d8cd98f00b204e9800998ecf8427e",
+		},
+		EventTime: since,
+	}
+
+	tests := []struct {
+		name     string
+		filter   LogFilter
+		expected bool
+	}{
+		{"Empty filter", LogFilter{}, true},
+		{"Prefix", LogFilter{PathPrefix: "/tmp/dl/"}, true},
+		{"Other prefix", LogFilter{PathPrefix: "/tmp/docs/"}, false},
+		{"Glob", LogFilter{PathGlob: "**/*.pdf"}, true},
+		{"Other glob", LogFilter{PathGlob: "/tmp/*.pdf"}, false},
+		{"Action", LogFilter{Actions: []string{"UPDATED", "CREATED"}}, true},
+		{"Other action", LogFilter{Actions: []string{"DELETED"}}, false},
+		{"Since is inclusive", LogFilter{Since: since}, true},
+		{"Until is exclusive", LogFilter{Until: since}, false},
+		{"MD5", LogFilter{MD5: "d41d8cd98f00b204e9800998ecf8427e"}, true},
+		{"SHA256 missing", LogFilter{SHA256: strings.Repeat("a", 64)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.filter.Matcher()(entry))
+		})
+	}
+}
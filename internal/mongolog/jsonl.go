@@ -0,0 +1,334 @@
+package mongolog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/pkg/atomicfile"
+)
+
+// jsonlRecord is a line of the JSONL file, LogEntry leaves its times out of
+// its JSON
+type jsonlRecord struct {
+	ID        string            `json:"id"`
+	CreatedAt time.Time         `json:"created_at"`
+	Details   map[string]string `json:"details"`
+	LogTime   string            `json:"time"`
+	EventTime time.Time         `json:"event_time"`
+	EventKey  string            `json:"event_key,omitempty"`
+	SHA256    string            `json:"sha256,omitempty"`
+	FuzzyHash string            `json:"fuzzy_hash,omitempty"`
+}
+
+// jsonlStore appends entries to a JSON-lines file and serves reads from a
+// copy of the file kept in memory. Every entry ever logged stays in memory
+// and each read scans all of them, so it is meant for development and small
+// setups; use sqlite or mongo for logs that keep growing. The baseline is
+// appended to a file of its own, one line per scan.
+type jsonlStore struct {
+	mu           sync.RWMutex
+	file         *os.File
+	entries      []LogEntry // oldest first
+	logged       map[string]bool
+	baselineFile *os.File
+	baseline     *Baseline // nil before the first scan
+}
+
+// NewJSONLLogStore opens or creates the JSONL file at path. A last line cut
+// short by a crash is dropped.
+func NewJSONLLogStore(path string) (LogStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log store directory: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log store file: %w", err)
+	}
+
+	entries, size, err := readJSONL(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// appends continue after the last complete line
+	err = file.Truncate(size)
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to prepare log store file: %w", err)
+	}
+
+	logged := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if entry.EventKey != "" {
+			logged[entry.EventKey] = true
+		}
+	}
+
+	baselineFile, baseline, err := openJSONLBaseline(strings.TrimSuffix(path, filepath.Ext(path)) + ".baseline.jsonl")
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &jsonlStore{
+		file:         file,
+		entries:      entries,
+		logged:       logged,
+		baselineFile: baselineFile,
+		baseline:     baseline,
+	}, nil
+}
+
+// openJSONLBaseline replays the baseline updates appended to path. Once
+// there is more than one they are compacted into a single update that
+// replaces the baseline, so the file only grows until the next open.
+func openJSONLBaseline(path string) (*os.File, *Baseline, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open baseline file: %w", err)
+	}
+
+	var baseline *Baseline
+	var size int64
+	updates := 0
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // an incomplete line if any was read
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to read baseline file: %w", err)
+		}
+		size += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var update BaselineUpdate
+		err = json.Unmarshal(line, &update)
+		if err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("failed to decode baseline file at byte %d: %w", size-int64(len(line)), err)
+		}
+		if baseline == nil {
+			baseline = &Baseline{}
+		}
+		baseline.apply(update)
+		updates++
+	}
+
+	if updates > 1 {
+		file.Close()
+		err = compactJSONLBaseline(path, baseline)
+		if err != nil {
+			return nil, nil, err
+		}
+		file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open baseline file: %w", err)
+		}
+		return file, baseline, nil
+	}
+
+	// appends continue after the last complete line
+	err = file.Truncate(size)
+	if err == nil {
+		_, err = file.Seek(size, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return nil, nil, fmt.Errorf("failed to prepare baseline file: %w", err)
+	}
+
+	return file, baseline, nil
+}
+
+// compactJSONLBaseline replaces the file at path with baseline as a single
+// update
+func compactJSONLBaseline(path string, baseline *Baseline) error {
+	line, err := json.Marshal(BaselineUpdate{
+		Replace:   true,
+		Set:       baseline.Files,
+		Roots:     baseline.Roots,
+		ScannedAt: baseline.ScannedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline: %w", err)
+	}
+
+	err = atomicfile.WriteFile(path, append(line, '\n'), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to compact baseline file: %w", err)
+	}
+
+	return nil
+}
+
+// readJSONL returns the entries of the file oldest first and the size of
+// its complete lines
+func readJSONL(file *os.File) ([]LogEntry, int64, error) {
+	var entries []LogEntry
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // an incomplete line if any was read
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read log store file: %w", err)
+		}
+		size += int64(len(line))
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var record jsonlRecord
+		err = json.Unmarshal(line, &record)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to decode log store file at byte %d: %w", size-int64(len(line)), err)
+		}
+		entries = insertSorted(entries, LogEntry{
+			ID:        record.ID,
+			CreatedAt: record.CreatedAt,
+			Details:   record.Details,
+			LogTime:   record.LogTime,
+			EventTime: record.EventTime,
+			EventKey:  record.EventKey,
+			SHA256:    record.SHA256,
+			FuzzyHash: record.FuzzyHash,
+		})
+	}
+
+	return entries, size, nil
+}
+
+func (l *jsonlStore) Write(ctx context.Context, logDetail map[string]string) (LogEntry, error) {
+	logEntries, err := l.WriteBatch(ctx, []map[string]string{logDetail})
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if len(logEntries) == 0 {
+		return LogEntry{}, ErrDuplicateEvent
+	}
+
+	return logEntries[0], nil
+}
+
+// WriteBatch appends the entries with a single write, a batch cut short by
+// a crash leaves a partial last line that is dropped on the next open
+func (l *jsonlStore) WriteBatch(ctx context.Context, logDetails []map[string]string) ([]LogEntry, error) {
+	if len(logDetails) == 0 {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var lines []byte
+	logEntries := uniqueEntries(l.logged, logDetails)
+	for _, logEntry := range logEntries {
+		line, err := json.Marshal(jsonlRecord{
+			ID:        logEntry.ID,
+			CreatedAt: logEntry.CreatedAt,
+			Details:   logEntry.Details,
+			LogTime:   logEntry.LogTime,
+			EventTime: logEntry.EventTime,
+			EventKey:  logEntry.EventKey,
+			SHA256:    logEntry.SHA256,
+			FuzzyHash: logEntry.FuzzyHash,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode log entry: %w", err)
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	if len(lines) == 0 {
+		return logEntries, nil
+	}
+
+	_, err := l.file.Write(lines)
+	if err != nil {
+		return nil, fmt.Errorf("failed to append log entries to jsonl store: %w", err)
+	}
+	for _, logEntry := range logEntries {
+		l.logged[logEntry.EventKey] = true
+		l.entries = insertSorted(l.entries, logEntry)
+	}
+
+	return logEntries, nil
+}
+
+func (l *jsonlStore) ReadLogsPaginated(ctx context.Context, query LogQuery) (LogPage, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return readPaginated(l.entries, query)
+}
+
+func (l *jsonlStore) ReadLogsAfter(ctx context.Context, query LogQuery) ([]LogEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return readAfter(l.entries, query)
+}
+
+func (l *jsonlStore) LoadBaseline(ctx context.Context) (Baseline, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.baseline == nil {
+		return Baseline{}, false, nil
+	}
+	return l.baseline.copy(), true, nil
+}
+
+// UpdateBaseline appends the update as one line, a line cut short by a
+// crash is dropped on the next open
+func (l *jsonlStore) UpdateBaseline(ctx context.Context, update BaselineUpdate) error {
+	line, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline update: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.baselineFile.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to append baseline update: %w", err)
+	}
+	if l.baseline == nil {
+		l.baseline = &Baseline{}
+	}
+	l.baseline.apply(update)
+
+	return nil
+}
+
+func (l *jsonlStore) Close(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return errors.Join(l.file.Close(), l.baselineFile.Close())
+}
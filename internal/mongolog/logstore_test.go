@@ -0,0 +1,487 @@
+package mongolog
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// logStoreFactories opens an empty store per backend, mongo only runs when
+// TEST_MONGO_URI points at a server
+func logStoreFactories() map[string]func(t *testing.T) LogStore {
+	return map[string]func(t *testing.T) LogStore{
+		"sqlite": func(t *testing.T) LogStore {
+			store, err := NewSQLiteLogStore(context.Background(), filepath.Join(t.TempDir(), "logs.db"))
+			require.NoError(t, err)
+			return store
+		},
+		"memory": func(t *testing.T) LogStore {
+			return NewMemoryLogStore(0)
+		},
+		"jsonl": func(t *testing.T) LogStore {
+			store, err := NewJSONLLogStore(filepath.Join(t.TempDir(), "logs.jsonl"))
+			require.NoError(t, err)
+			return store
+		},
+		"mongo": func(t *testing.T) LogStore {
+			mongoURI := os.Getenv("TEST_MONGO_URI")
+			if mongoURI == "" {
+				t.Skip("TEST_MONGO_URI is not set")
+			}
+			store, err := NewMongoLogStore(context.Background(), mongoURI, "logsDB_test", "logs_"+uuid.NewString())
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				store.(*logStore).collection.Drop(context.Background())
+				store.(*logStore).baselineFiles.Drop(context.Background())
+				store.(*logStore).baselineScans.Drop(context.Background())
+			})
+			return store
+		},
+	}
+}
+
+// go test -v -cover -run TestLogStoreConformance ./internal/mongolog
+func TestLogStoreConformance(t *testing.T) {
+	for name, newStore := range logStoreFactories() {
+		t.Run(name, func(t *testing.T) {
+			t.Run("WriteAndRead", func(t *testing.T) { testWriteAndRead(t, newStore(t)) })
+			t.Run("Pagination", func(t *testing.T) { testPagination(t, newStore(t)) })
+			t.Run("Filters", func(t *testing.T) { testFilters(t, newStore(t)) })
+			t.Run("ReadAfter", func(t *testing.T) { testReadAfter(t, newStore(t)) })
+			t.Run("WriteBatch", func(t *testing.T) { testWriteBatch(t, newStore(t)) })
+			t.Run("Duplicates", func(t *testing.T) { testDuplicates(t, newStore(t)) })
+			t.Run("Hashes", func(t *testing.T) { testHashes(t, newStore(t)) })
+			t.Run("Baseline", func(t *testing.T) { testBaseline(t, newStore(t)) })
+		})
+	}
+}
+
+// writeLogs writes count entries for target paths /watched/file0.txt ...,
+// the actions alternate between CREATED and UPDATED
+func writeLogs(t *testing.T, store LogStore, count int) []LogEntry {
+	entries := make([]LogEntry, 0, count)
+	for i := 0; i < count; i++ {
+		action := "CREATED"
+		if i%2 == 1 {
+			action = "UPDATED"
+		}
+		entry, err := store.Write(context.Background(), map[string]string{
+			"eid":         uuid.NewString(),
+			"target_path": "/watched/file" + strconv.Itoa(i) + ".txt",
+			"action":      action,
+			"time":        strconv.Itoa(1700000000 + i),
+			"md5":         "0123456789abcdef0123456789abcde" + strconv.Itoa(i%10),
+		})
+		require.NoError(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func ids(entries []LogEntry) []string {
+	res := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		res = append(res, entry.ID)
+	}
+	return res
+}
+
+// newestFirst returns the ids of entries sorted the way pages are
+func newestFirst(entries []LogEntry) []string {
+	sorted := append([]LogEntry(nil), entries...)
+	sortOldestFirst(sorted)
+	res := ids(sorted)
+	for i, j := 0, len(res)-1; i < j; i, j = i+1, j-1 {
+		res[i], res[j] = res[j], res[i]
+	}
+	return res
+}
+
+func testWriteAndRead(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	written := writeLogs(t, store, 1)[0]
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), written.EventTime)
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, written, page.Items[0])
+	assert.Equal(t, int64(1), page.Total)
+	assert.Empty(t, page.NextCursor)
+}
+
+func testHashes(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	sha := strings.Repeat("ab", 32)
+	written, err := store.Write(context.Background(), map[string]string{
+		"eid":         uuid.NewString(),
+		"target_path": "/tmp/hashed.txt",
+		"action":      "UPDATED",
+		"time":        "1700000000",
+		"sha256":      sha,
+		"fuzzy_hash":  "3:abc:def",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, sha, written.SHA256)
+	assert.Equal(t, "3:abc:def", written.FuzzyHash)
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Filter: LogFilter{SHA256: sha}})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, written, page.Items[0])
+}
+
+func testPagination(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	expected := newestFirst(writeLogs(t, store, 7))
+
+	// by cursor
+	var got []string
+	query := LogQuery{Limit: 3}
+	for {
+		page, err := store.ReadLogsPaginated(context.Background(), query)
+		require.NoError(t, err)
+		assert.Equal(t, int64(7), page.Total)
+		got = append(got, ids(page.Items)...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, expected, got)
+
+	// by offset
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 3, Offset: 6})
+	require.NoError(t, err)
+	assert.Equal(t, expected[6:], ids(page.Items))
+	assert.Empty(t, page.NextCursor)
+
+	_, err = store.ReadLogsPaginated(context.Background(), LogQuery{Cursor: "e30"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func testFilters(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	entries := writeLogs(t, store, 12)
+
+	tests := []struct {
+		name     string
+		filter   LogFilter
+		expected []LogEntry
+	}{
+		{"Path prefix", LogFilter{PathPrefix: "/watched/file1"}, []LogEntry{entries[1], entries[10], entries[11]}},
+		{"Path glob", LogFilter{PathGlob: "/watched/file?.txt"}, entries[:10]},
+		{"Prefix and glob", LogFilter{PathPrefix: "/watched/file1", PathGlob: "**/file?.txt"}, []LogEntry{entries[1]}},
+		{"Actions", LogFilter{Actions: []string{"UPDATED"}}, []LogEntry{entries[1], entries[3], entries[5], entries[7], entries[9], entries[11]}},
+		{"Time range", LogFilter{Since: time.Unix(1700000002, 0), Until: time.Unix(1700000004, 0)}, entries[2:4]},
+		{"MD5", LogFilter{MD5: "0123456789abcdef0123456789abcde1"}, []LogEntry{entries[1], entries[11]}},
+		{"No match", LogFilter{SHA256: "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Filter: tt.filter, Limit: 20})
+			require.NoError(t, err)
+			assert.Equal(t, newestFirst(tt.expected), ids(page.Items))
+			assert.Equal(t, int64(len(tt.expected)), page.Total)
+		})
+	}
+}
+
+func testReadAfter(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	entries := writeLogs(t, store, 6)
+	sortOldestFirst(entries)
+
+	logs, err := store.ReadLogsAfter(context.Background(), LogQuery{Cursor: entries[1].Cursor(), Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, ids(entries[2:5]), ids(logs))
+
+	logs, err = store.ReadLogsAfter(context.Background(), LogQuery{
+		Cursor: entries[0].Cursor(),
+		Filter: LogFilter{Actions: []string{"CREATED"}},
+	})
+	require.NoError(t, err)
+	for _, entry := range logs {
+		assert.Equal(t, "CREATED", entry.Details["action"])
+	}
+
+	_, err = store.ReadLogsAfter(context.Background(), LogQuery{Cursor: "e30"})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func testWriteBatch(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	entries, err := store.WriteBatch(context.Background(), nil)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = store.WriteBatch(context.Background(), []map[string]string{
+		{"target_path": "/watched/a.txt", "action": "CREATED", "time": "1700000000"},
+		{"target_path": "/watched/b.txt", "action": "UPDATED", "time": "1700000001"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "/watched/b.txt", entries[1].Details["target_path"])
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, newestFirst(entries), ids(page.Items))
+}
+
+func testDuplicates(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+
+	event := map[string]string{"eid": "7", "target_path": "/watched/a.txt", "action": "CREATED", "time": "1700000000"}
+	sameSecond := map[string]string{"eid": "8", "target_path": "/watched/a.txt", "action": "UPDATED", "time": "1700000000"}
+
+	written, err := store.Write(context.Background(), event)
+	require.NoError(t, err)
+
+	_, err = store.Write(context.Background(), event)
+	assert.ErrorIs(t, err, ErrDuplicateEvent)
+
+	// a re-read batch only writes the events that are new, once
+	entries, err := store.WriteBatch(context.Background(), []map[string]string{event, sameSecond, sameSecond})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "8", entries[0].Details["eid"])
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, newestFirst([]LogEntry{written, entries[0]}), ids(page.Items))
+}
+
+func testBaseline(t *testing.T, store LogStore) {
+	defer store.Close(context.Background())
+	ctx := context.Background()
+
+	_, ok, err := store.LoadBaseline(ctx)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	a := FileState{Watch: "/watched", Size: 3, Mode: "-rw-r--r--", UID: "1000", GID: "1000", MTime: 1700000000, SHA256: "abc"}
+	b := FileState{Watch: "/watched", Size: 1 << 40, Mode: "-rw-------", MTime: 1700000001}
+	scannedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, store.UpdateBaseline(ctx, BaselineUpdate{
+		Replace:   true,
+		Set:       map[string]FileState{"/watched/a.txt": a, "/watched/b.iso": b},
+		Roots:     []string{"/watched"},
+		ScannedAt: scannedAt,
+	}))
+
+	// a later scan only writes what changed
+	a.Size, a.SHA256 = 4, "abcd"
+	require.NoError(t, store.UpdateBaseline(ctx, BaselineUpdate{
+		Set:       map[string]FileState{"/watched/a.txt": a},
+		Deleted:   []string{"/watched/b.iso"},
+		Roots:     []string{"/watched"},
+		ScannedAt: scannedAt.Add(time.Hour),
+	}))
+
+	baseline, ok, err := store.LoadBaseline(ctx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, map[string]FileState{"/watched/a.txt": a}, baseline.Files)
+	assert.Equal(t, []string{"/watched"}, baseline.Roots)
+	assert.True(t, scannedAt.Add(time.Hour).Equal(baseline.ScannedAt))
+
+	// replace drops the files the update doesn't set
+	require.NoError(t, store.UpdateBaseline(ctx, BaselineUpdate{
+		Replace:   true,
+		Set:       map[string]FileState{"/watched/b.iso": b},
+		Roots:     []string{"/watched"},
+		ScannedAt: scannedAt.Add(2 * time.Hour),
+	}))
+	baseline, _, err = store.LoadBaseline(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]FileState{"/watched/b.iso": b}, baseline.Files)
+}
+
+// go test -v -cover -run TestMemoryLogStore_Capacity ./internal/mongolog
+func TestMemoryLogStore_Capacity(t *testing.T) {
+	store := NewMemoryLogStore(3)
+	entries := writeLogs(t, store, 5)
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, newestFirst(entries)[:3], ids(page.Items))
+	assert.Equal(t, int64(3), page.Total)
+}
+
+// go test -v -cover -run TestSQLiteLogStore_AddsColumns ./internal/mongolog
+func TestSQLiteLogStore_AddsColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+
+	// a database created before event keys and fuzzy hashes were stored
+	db, err := sql.Open("sqlite", path)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+CREATE TABLE logs (
+	id          TEXT PRIMARY KEY,
+	created_at  INTEGER NOT NULL,
+	event_time  INTEGER NOT NULL,
+	log_time    TEXT NOT NULL,
+	target_path TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL DEFAULT '',
+	md5         TEXT NOT NULL DEFAULT '',
+	sha256      TEXT NOT NULL DEFAULT '',
+	details     TEXT NOT NULL
+);`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	store, err := NewSQLiteLogStore(context.Background(), path)
+	require.NoError(t, err)
+	testDuplicates(t, store)
+
+	store, err = NewSQLiteLogStore(context.Background(), path)
+	require.NoError(t, err)
+	testHashes(t, store)
+}
+
+// go test -v -cover -run TestSQLiteLogStore_PathCharacters ./internal/mongolog
+func TestSQLiteLogStore_PathCharacters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs?v=1#a %", "logs.db")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+
+	store, err := NewSQLiteLogStore(context.Background(), path)
+	require.NoError(t, err)
+	writeLogs(t, store, 1)
+	require.NoError(t, store.Close(context.Background()))
+
+	assert.FileExists(t, path)
+}
+
+// go test -v -cover -race -run TestMemoryLogStore_Concurrent ./internal/mongolog
+func TestMemoryLogStore_Concurrent(t *testing.T) {
+	store := NewMemoryLogStore(50)
+
+	// require may only stop the test from its own goroutine, the errors are
+	// checked once every goroutine is done
+	errs := make(chan error, 8*25)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				_, err := store.Write(context.Background(), map[string]string{
+					"eid":         uuid.NewString(),
+					"target_path": "/watched/file" + strconv.Itoa(j) + ".txt",
+					"time":        strconv.Itoa(1700000000 + j),
+				})
+				errs <- err
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				_, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 5})
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 50)
+}
+
+// go test -v -cover -run TestJSONLLogStore_Reopen ./internal/mongolog
+func TestJSONLLogStore_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+
+	store, err := NewJSONLLogStore(path)
+	require.NoError(t, err)
+	entries := writeLogs(t, store, 2)
+	require.NoError(t, store.Close(context.Background()))
+
+	// a write cut short by a crash
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"id":"cut-sh`)
+	require.NoError(t, err)
+	file.Close()
+
+	store, err = NewJSONLLogStore(path)
+	require.NoError(t, err)
+	entries = append(entries, writeLogs(t, store, 1)...)
+
+	page, err := store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, newestFirst(entries), ids(page.Items))
+	require.NoError(t, store.Close(context.Background()))
+
+	store, err = NewJSONLLogStore(path)
+	require.NoError(t, err)
+	defer store.Close(context.Background())
+	page, err = store.ReadLogsPaginated(context.Background(), LogQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, newestFirst(entries), ids(page.Items))
+}
+
+// go test -v -cover -run TestLogStore_BaselineReopen ./internal/mongolog
+func TestLogStore_BaselineReopen(t *testing.T) {
+	dir := t.TempDir()
+	stores := map[string]func() (LogStore, error){
+		"sqlite": func() (LogStore, error) {
+			return NewSQLiteLogStore(context.Background(), filepath.Join(dir, "logs.db"))
+		},
+		"jsonl": func() (LogStore, error) {
+			return NewJSONLLogStore(filepath.Join(dir, "logs.jsonl"))
+		},
+	}
+
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			state := FileState{Watch: "/watched", Size: 1, Mode: "-rw-r--r--", MTime: 1700000000, SHA256: "abc"}
+
+			store, err := open()
+			require.NoError(t, err)
+			for i := 0; i < 3; i++ {
+				require.NoError(t, store.UpdateBaseline(ctx, BaselineUpdate{
+					Replace:   i == 0,
+					Set:       map[string]FileState{fmt.Sprintf("/watched/file%d.txt", i): state},
+					Deleted:   []string{"/watched/file0.txt"},
+					Roots:     []string{"/watched"},
+					ScannedAt: time.Unix(1700000000+int64(i), 0).UTC(),
+				}))
+			}
+			require.NoError(t, store.Close(ctx))
+
+			store, err = open()
+			require.NoError(t, err)
+			defer store.Close(ctx)
+			baseline, ok, err := store.LoadBaseline(ctx)
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, map[string]FileState{"/watched/file1.txt": state, "/watched/file2.txt": state}, baseline.Files)
+			assert.True(t, time.Unix(1700000002, 0).Equal(baseline.ScannedAt))
+		})
+	}
+}
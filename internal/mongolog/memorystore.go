@@ -0,0 +1,100 @@
+package mongolog
+
+import (
+	"context"
+	"sync"
+)
+
+// memoryStore keeps the logs in memory, for tests and runs that don't need
+// to keep them. Once it holds capacity entries the oldest is dropped for
+// every new one.
+type memoryStore struct {
+	mu       sync.RWMutex
+	capacity int
+	entries  []LogEntry // oldest first
+	logged   map[string]bool
+	baseline *Baseline // nil before the first scan
+}
+
+// NewMemoryLogStore returns an empty in-memory store, a capacity of 0 keeps
+// every entry
+func NewMemoryLogStore(capacity int) LogStore {
+	return &memoryStore{
+		capacity: capacity,
+		logged:   make(map[string]bool),
+	}
+}
+
+func (l *memoryStore) Write(ctx context.Context, logDetail map[string]string) (LogEntry, error) {
+	logEntries, err := l.WriteBatch(ctx, []map[string]string{logDetail})
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if len(logEntries) == 0 {
+		return LogEntry{}, ErrDuplicateEvent
+	}
+
+	return logEntries[0], nil
+}
+
+func (l *memoryStore) WriteBatch(ctx context.Context, logDetails []map[string]string) ([]LogEntry, error) {
+	if len(logDetails) == 0 {
+		return nil, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	logEntries := uniqueEntries(l.logged, logDetails)
+	for _, logEntry := range logEntries {
+		l.logged[logEntry.EventKey] = true
+		l.entries = insertSorted(l.entries, logEntry)
+		if l.capacity > 0 && len(l.entries) > l.capacity {
+			delete(l.logged, l.entries[0].EventKey)
+			l.entries[0] = LogEntry{} // append reallocates before the array grows past capacity
+			l.entries = l.entries[1:]
+		}
+	}
+
+	return logEntries, nil
+}
+
+func (l *memoryStore) ReadLogsPaginated(ctx context.Context, query LogQuery) (LogPage, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return readPaginated(l.entries, query)
+}
+
+func (l *memoryStore) ReadLogsAfter(ctx context.Context, query LogQuery) ([]LogEntry, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return readAfter(l.entries, query)
+}
+
+func (l *memoryStore) LoadBaseline(ctx context.Context) (Baseline, bool, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.baseline == nil {
+		return Baseline{}, false, nil
+	}
+	return l.baseline.copy(), true, nil
+}
+
+func (l *memoryStore) UpdateBaseline(ctx context.Context, update BaselineUpdate) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.baseline == nil {
+		l.baseline = &Baseline{}
+	}
+	l.baseline.apply(update)
+
+	return nil
+}
+
+func (l *memoryStore) Close(ctx context.Context) error {
+	return nil
+}
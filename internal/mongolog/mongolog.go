@@ -2,6 +2,9 @@ package mongolog
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -14,13 +17,28 @@ import (
 
 //go:generate mockgen -destination=../../mocks/mongolog/mock_mongolog.go -package=mongologmock -source=mongolog.go
 type LogStore interface {
-	Write(ctx context.Context, logDetail map[string]string) error
+	Write(ctx context.Context, logDetail map[string]string) (LogEntry, error)
+	// WriteBatch writes the entries in one round trip, they are only
+	// returned when every entry was written
+	WriteBatch(ctx context.Context, logDetails []map[string]string) ([]LogEntry, error)
 	Close(ctx context.Context) error
-	ReadLogsPaginated(ctx context.Context, page, pageSize int64) ([]LogEntry, error)
+	ReadLogsPaginated(ctx context.Context, query LogQuery) (LogPage, error)
+	ReadLogsAfter(ctx context.Context, query LogQuery) ([]LogEntry, error)
+
+	// LoadBaseline returns the baseline of the last scan, ok is false
+	// before the first one
+	LoadBaseline(ctx context.Context) (baseline Baseline, ok bool, err error)
+	// UpdateBaseline writes what a scan changed in the baseline
+	UpdateBaseline(ctx context.Context, update BaselineUpdate) error
 }
 
+// ErrDuplicateEvent is returned by Write when the event was already logged
+var ErrDuplicateEvent = errors.New("event already logged")
+
 type logStore struct {
-	collection *mongo.Collection
+	collection    *mongo.Collection
+	baselineFiles *mongo.Collection // a document per file, by path
+	baselineScans *mongo.Collection // the last scan
 }
 
 func NewMongoLogStore(ctx context.Context, mongoURI, databaseName, collectionName string) (LogStore, error) {
@@ -36,36 +54,73 @@ func NewMongoLogStore(ctx context.Context, mongoURI, databaseName, collectionNam
 		return nil, fmt.Errorf("failed to list collections: %w", err)
 	}
 
-	var collection *mongo.Collection
 	if len(collections) == 0 {
 		err := db.CreateCollection(ctx, collectionName)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create collection: %w", err)
 		}
+	}
+	collection := db.Collection(collectionName)
 
-		collection = db.Collection(collectionName)
-
-		err = createIndex(ctx, collection)
-		if err != nil {
-			return nil, fmt.Errorf("failed to created index on collection: %w", err)
-		}
+	// indexes added in later versions are created on existing collections too
+	err = createIndexes(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to created index on collection: %w", err)
+	}
 
-	} else {
-		collection = db.Collection(collectionName)
+	err = backfillEventTime(ctx, collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate collection: %w", err)
 	}
 
 	return &logStore{
-		collection: collection,
+		collection:    collection,
+		baselineFiles: db.Collection("baseline_files"),
+		baselineScans: db.Collection("baseline_scans"),
 	}, nil
 }
 
-func createIndex(ctx context.Context, collection *mongo.Collection) error {
-	indexModel := mongo.IndexModel{
-		Keys:    bson.D{{Key: "created_at", Value: -1}}, // -1 for descending order
-		Options: options.Index().SetName("created_at_index"),
+// createIndexes creates the indexes backing the sort order and LogFilter,
+// creating an index that already exists is a no-op
+func createIndexes(ctx context.Context, collection *mongo.Collection) error {
+	indexModels := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}}, // -1 for descending order
+			Options: options.Index().SetName("created_at_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}, // keyset pagination
+			Options: options.Index().SetName("created_at_id_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "details.target_path", Value: 1}},
+			Options: options.Index().SetName("target_path_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "details.action", Value: 1}, {Key: "created_at", Value: -1}},
+			Options: options.Index().SetName("action_created_at_index"),
+		},
+		{
+			Keys:    bson.D{{Key: "event_time", Value: -1}},
+			Options: options.Index().SetName("event_time_index"),
+		},
+		{
+			// entries written before event keys existed don't have one
+			Keys: bson.D{{Key: "event_key", Value: 1}},
+			Options: options.Index().SetName("event_key_index").SetUnique(true).
+				SetPartialFilterExpression(bson.D{{Key: "event_key", Value: bson.D{{Key: "$exists", Value: true}}}}),
+		},
+		{
+			Keys:    bson.D{{Key: "details.md5", Value: 1}},
+			Options: options.Index().SetName("md5_index").SetSparse(true),
+		},
+		{
+			Keys:    bson.D{{Key: "details.sha256", Value: 1}},
+			Options: options.Index().SetName("sha256_index").SetSparse(true),
+		},
 	}
 
-	_, err := collection.Indexes().CreateOne(ctx, indexModel)
+	_, err := collection.Indexes().CreateMany(ctx, indexModels)
 	if err != nil {
 		return fmt.Errorf("failed to create index: %v", err)
 	}
@@ -73,27 +128,218 @@ func createIndex(ctx context.Context, collection *mongo.Collection) error {
 	return nil
 }
 
-func (l *logStore) Write(ctx context.Context, logDetail map[string]string) error {
+// backfillEventTime sets event_time on entries written before it existed,
+// from their time or, when that can't be parsed, their created_at. Time
+// filters would otherwise leave those entries out. Once every entry has
+// one the update matches nothing.
+func backfillEventTime(ctx context.Context, collection *mongo.Collection) error {
+	missing := bson.D{{Key: "event_time", Value: bson.D{{Key: "$exists", Value: false}}}}
+	eventTime := bson.D{{Key: "$dateFromString", Value: bson.D{
+		{Key: "dateString", Value: "$time"},
+		{Key: "onError", Value: "$created_at"},
+		{Key: "onNull", Value: "$created_at"},
+	}}}
+
+	_, err := collection.UpdateMany(ctx, missing, mongo.Pipeline{
+		{{Key: "$set", Value: bson.D{{Key: "event_time", Value: eventTime}}}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to backfill event_time: %w", err)
+	}
+
+	return nil
+}
+
+func (l *logStore) Write(ctx context.Context, logDetail map[string]string) (LogEntry, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	logEntry := newLogEntry(logDetail)
+
+	_, err := l.collection.InsertOne(ctxWithTimeout, logEntry)
+	if mongo.IsDuplicateKeyError(err) {
+		return LogEntry{}, ErrDuplicateEvent
+	}
+	if err != nil {
+		return LogEntry{}, fmt.Errorf("failed to insert log entry into mongolog store: %w", err)
+	}
+
+	return logEntry, nil
+}
+
+func (l *logStore) WriteBatch(ctx context.Context, logDetails []map[string]string) ([]LogEntry, error) {
+	if len(logDetails) == 0 {
+		return nil, nil
+	}
+
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
-	now := time.Now()
+	logEntries := make([]LogEntry, 0, len(logDetails))
+	documents := make([]interface{}, 0, len(logDetails))
+	for _, logDetail := range logDetails {
+		logEntry := newLogEntry(logDetail)
+		logEntries = append(logEntries, logEntry)
+		documents = append(documents, logEntry)
+	}
+
+	// unordered keeps inserting past a failed entry, the batch only fails
+	// when an entry failed for another reason than being logged already
+	_, err := l.collection.InsertMany(ctxWithTimeout, documents, options.InsertMany().SetOrdered(false))
+	if err != nil {
+		duplicates, ok := duplicateIndexes(err)
+		if !ok {
+			return nil, fmt.Errorf("failed to insert log entries into mongolog store: %w", err)
+		}
+
+		inserted := logEntries[:0]
+		for i, logEntry := range logEntries {
+			if !duplicates[i] {
+				inserted = append(inserted, logEntry)
+			}
+		}
+		logEntries = inserted
+	}
+
+	return logEntries, nil
+}
+
+// duplicateIndexes returns the positions in the batch rejected by the
+// event_key index, ok is false when anything else failed
+func duplicateIndexes(err error) (duplicates map[int]bool, ok bool) {
+	var bulkErr mongo.BulkWriteException
+	if !errors.As(err, &bulkErr) || bulkErr.WriteConcernError != nil {
+		return nil, false
+	}
+
+	duplicates = make(map[int]bool, len(bulkErr.WriteErrors))
+	for _, writeErr := range bulkErr.WriteErrors {
+		if !mongo.IsDuplicateKeyError(writeErr.WriteError) {
+			return nil, false
+		}
+		duplicates[writeErr.Index] = true
+	}
+
+	return duplicates, true
+}
+
+// newLogEntry builds the entry stored for logDetail. Every store keeps
+// milliseconds in UTC like mongo, which keeps the returned entry and its
+// cursor identical to what is read back. The hashes of the file, from the
+// event source or the tracker, are kept as fields of their own.
+func newLogEntry(logDetail map[string]string) LogEntry {
+	now := time.Now().UTC().Truncate(time.Millisecond)
 	logTime := now
 	changeTime, err := strconv.ParseInt(logDetail["time"], 10, 64)
 	if err == nil {
 		logTime = time.Unix(changeTime, 0)
 	}
 
-	logEntry := LogEntry{
+	return LogEntry{
 		ID:        uuid.NewString(),
 		CreatedAt: now,
 		Details:   logDetail,
 		LogTime:   logTime.Format(time.RFC3339),
+		EventTime: logTime.UTC(),
+		EventKey:  eventKey(logDetail),
+		SHA256:    logDetail["sha256"],
+		FuzzyHash: logDetail["fuzzy_hash"],
+	}
+}
+
+// eventKey identifies the osquery event behind logDetail, an event read
+// twice gets the same key so the stores keep it once
+func eventKey(logDetail map[string]string) string {
+	h := sha256.New()
+	for _, field := range []string{"eid", "target_path", "time", "action"} {
+		h.Write([]byte(logDetail[field]))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lastScanID is the _id of the one document in baseline_scans
+const lastScanID = "last"
+
+type baselineFile struct {
+	Path      string `bson:"_id"`
+	FileState `bson:",inline"`
+}
+
+type baselineScan struct {
+	ID        string    `bson:"_id"`
+	Roots     []string  `bson:"roots"`
+	ScannedAt time.Time `bson:"scanned_at"`
+}
+
+func (l *logStore) LoadBaseline(ctx context.Context) (Baseline, bool, error) {
+	var scan baselineScan
+	err := l.baselineScans.FindOne(ctx, bson.D{{Key: "_id", Value: lastScanID}}).Decode(&scan)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Baseline{}, false, nil
+	}
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	cursor, err := l.baselineFiles.Find(ctx, bson.D{})
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	baseline := Baseline{
+		Files:     make(map[string]FileState),
+		Roots:     scan.Roots,
+		ScannedAt: scan.ScannedAt.UTC(),
+	}
+	for cursor.Next(ctx) {
+		var file baselineFile
+		err = cursor.Decode(&file)
+		if err != nil {
+			return Baseline{}, false, fmt.Errorf("failed to decode baseline: %w", err)
+		}
+		baseline.Files[file.Path] = file.FileState
+	}
+	if err = cursor.Err(); err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	return baseline, true, nil
+}
+
+// UpdateBaseline writes the changed files, then the scan. The scan is only
+// written when the files were, a failed update is done again with Replace.
+func (l *logStore) UpdateBaseline(ctx context.Context, update BaselineUpdate) error {
+	if update.Replace {
+		_, err := l.baselineFiles.DeleteMany(ctx, bson.D{})
+		if err != nil {
+			return fmt.Errorf("failed to clear baseline: %w", err)
+		}
+	}
+
+	models := make([]mongo.WriteModel, 0, len(update.Set)+len(update.Deleted))
+	for path, state := range update.Set {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.D{{Key: "_id", Value: path}}).
+			SetReplacement(baselineFile{Path: path, FileState: state}).
+			SetUpsert(true))
+	}
+	for _, path := range update.Deleted {
+		models = append(models, mongo.NewDeleteOneModel().SetFilter(bson.D{{Key: "_id", Value: path}}))
+	}
+	if len(models) > 0 {
+		_, err := l.baselineFiles.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("failed to update baseline: %w", err)
+		}
 	}
 
-	_, err = l.collection.InsertOne(ctxWithTimeout, logEntry)
+	scan := baselineScan{ID: lastScanID, Roots: update.Roots, ScannedAt: update.ScannedAt}
+	_, err := l.baselineScans.ReplaceOne(ctx, bson.D{{Key: "_id", Value: lastScanID}}, scan, options.Replace().SetUpsert(true))
 	if err != nil {
-		return fmt.Errorf("failed to insert log entry into mongolog store: %w", err)
+		return fmt.Errorf("failed to update baseline: %w", err)
 	}
 
 	return nil
@@ -14,22 +14,122 @@ type LogEntry struct {
 	CreatedAt time.Time         `bson:"created_at" json:"-"` // retains the full time precision to ensure accurate and performant sorting
 	Details   map[string]string `bson:"details" json:"details"`
 	LogTime   string            `bson:"time" json:"logTime"`
+	EventTime time.Time         `bson:"event_time" json:"-"` // LogTime as a date so it can be range queried
+	EventKey  string            `bson:"event_key" json:"-"`  // unique per osquery event, see eventKey
+	SHA256    string            `bson:"sha256,omitempty" json:"sha256,omitempty"`
+	FuzzyHash string            `bson:"fuzzy_hash,omitempty" json:"fuzzyHash,omitempty"` // ssdeep digest of the file
 }
 
-func (l *logStore) ReadLogsPaginated(ctx context.Context, limit, offset int64) ([]LogEntry, error) {
+// LogQuery selects a page of logs, newest first. Pages are continued either
+// with Cursor, the NextCursor of the previous page, or with Offset.
+type LogQuery struct {
+	Filter LogFilter
+	Limit  int64
+	Offset int64
+	Cursor string
+}
+
+// LogPage is a page of logs, NextCursor is empty on the last page. Total
+// counts the logs matching the filter across all pages, it is taken from the
+// collection metadata when there is no filter and TotalEstimated is set.
+type LogPage struct {
+	Items          []LogEntry
+	NextCursor     string
+	Total          int64
+	TotalEstimated bool
+}
+
+func (l *logStore) ReadLogsPaginated(ctx context.Context, query LogQuery) (LogPage, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	limit := query.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	filter := query.Filter.query()
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return LogPage{}, err
+		}
+		filter = bson.D{{Key: "$and", Value: bson.A{filter, c.older()}}}
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(query.Offset)
+	// one extra to know whether there is a next page
+	findOptions.SetLimit(limit + 1)
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}) // Sort by date created descending, _id breaks ties
+
+	cursor, err := l.collection.Find(ctxWithTimeout, filter, findOptions)
+	if err != nil {
+		return LogPage{}, fmt.Errorf("failed to fetch logs: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var logs []LogEntry
+	if err := cursor.All(ctx, &logs); err != nil {
+		return LogPage{}, fmt.Errorf("failed to decode logs: %w", err)
+	}
+
+	page := LogPage{Items: logs}
+	if int64(len(logs)) > limit {
+		page.Items = logs[:limit]
+		page.NextCursor = page.Items[limit-1].Cursor()
+	}
+
+	page.Total, page.TotalEstimated, err = l.count(ctxWithTimeout, query.Filter)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	return page, nil
+}
+
+// count counts the logs matching filter, an unfiltered count is estimated
+// as counting every document gets slow on large collections
+func (l *logStore) count(ctx context.Context, filter LogFilter) (total int64, estimated bool, err error) {
+	query := filter.query()
+	if len(query) == 0 {
+		total, err = l.collection.EstimatedDocumentCount(ctx)
+		if err != nil {
+			return 0, false, fmt.Errorf("failed to count logs: %w", err)
+		}
+		return total, true, nil
+	}
+
+	total, err = l.collection.CountDocuments(ctx, query)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	return total, false, nil
+}
+
+// ReadLogsAfter returns the entries written after query.Cursor, oldest first,
+// for a reader catching up on what it missed. Offset is ignored.
+func (l *logStore) ReadLogsAfter(ctx context.Context, query LogQuery) ([]LogEntry, error) {
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
 
+	limit := query.Limit
 	if limit < 1 {
 		limit = 10
 	}
 
+	c, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	filter := bson.D{{Key: "$and", Value: bson.A{query.Filter.query(), c.newer()}}}
+
 	findOptions := options.Find()
-	findOptions.SetSkip(offset)
 	findOptions.SetLimit(limit)
-	findOptions.SetSort(bson.D{{Key: "created_at", Value: -1}}) // Sort by date created descending
+	findOptions.SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
 
-	cursor, err := l.collection.Find(ctxWithTimeout, bson.D{}, findOptions)
+	cursor, err := l.collection.Find(ctxWithTimeout, filter, findOptions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch logs: %w", err)
 	}
@@ -0,0 +1,100 @@
+package mongolog
+
+// readPaginated is ReadLogsPaginated over entries sorted oldest first, for
+// the stores that scan their entries instead of querying an index
+func readPaginated(entries []LogEntry, query LogQuery) (LogPage, error) {
+	limit := query.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	var c *logCursor
+	if query.Cursor != "" {
+		decoded, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return LogPage{}, err
+		}
+		c = &decoded
+	}
+
+	match := query.Filter.Matcher()
+	var page LogPage
+	var skipped int64
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if !match(entry) {
+			continue
+		}
+
+		// the total ignores the cursor and offset, like count does
+		page.Total++
+		switch {
+		case c != nil && c.compare(entry) >= 0:
+		case skipped < query.Offset:
+			skipped++
+		case int64(len(page.Items)) < limit:
+			page.Items = append(page.Items, entry)
+		case page.NextCursor == "":
+			page.NextCursor = page.Items[limit-1].Cursor()
+		}
+	}
+
+	return page, nil
+}
+
+// readAfter is ReadLogsAfter over entries sorted oldest first
+func readAfter(entries []LogEntry, query LogQuery) ([]LogEntry, error) {
+	limit := query.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	c, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	match := query.Filter.Matcher()
+	var logs []LogEntry
+	for _, entry := range entries {
+		if int64(len(logs)) == limit {
+			break
+		}
+		if c.compare(entry) > 0 && match(entry) {
+			logs = append(logs, entry)
+		}
+	}
+
+	return logs, nil
+}
+
+// insertSorted adds entry to entries sorted oldest first, entries are
+// nearly always written in order so it is appended
+func insertSorted(entries []LogEntry, entry LogEntry) []LogEntry {
+	entries = append(entries, entry)
+	position := logCursor{CreatedAt: entry.CreatedAt, ID: entry.ID}
+	i := len(entries) - 1
+	for ; i > 0 && position.compare(entries[i-1]) > 0; i-- {
+		entries[i] = entries[i-1]
+	}
+	entries[i] = entry
+
+	return entries
+}
+
+// uniqueEntries builds the entries of logDetails, leaving out the events in
+// logged and the repeats within the batch
+func uniqueEntries(logged map[string]bool, logDetails []map[string]string) []LogEntry {
+	logEntries := make([]LogEntry, 0, len(logDetails))
+	batch := make(map[string]bool, len(logDetails))
+	for _, logDetail := range logDetails {
+		logEntry := newLogEntry(logDetail)
+		if logged[logEntry.EventKey] || batch[logEntry.EventKey] {
+			continue
+		}
+		batch[logEntry.EventKey] = true
+		logEntries = append(logEntries, logEntry)
+	}
+
+	return logEntries
+}
@@ -0,0 +1,491 @@
+package mongolog
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"modernc.org/sqlite"
+)
+
+const sqliteTable = `
+CREATE TABLE IF NOT EXISTS logs (
+	id          TEXT PRIMARY KEY,
+	created_at  INTEGER NOT NULL, -- unix milliseconds
+	event_time  INTEGER NOT NULL, -- unix milliseconds
+	log_time    TEXT NOT NULL,
+	target_path TEXT NOT NULL DEFAULT '',
+	action      TEXT NOT NULL DEFAULT '',
+	md5         TEXT NOT NULL DEFAULT '',
+	sha256      TEXT NOT NULL DEFAULT '',
+	details     TEXT NOT NULL, -- JSON object
+	event_key   TEXT NOT NULL DEFAULT '',
+	fuzzy_hash  TEXT NOT NULL DEFAULT ''
+);
+`
+
+// sqliteBaselineTables keep the baseline of the last scan, a file per row
+// so a scan only writes the files that changed
+const sqliteBaselineTables = `
+CREATE TABLE IF NOT EXISTS baseline_files (
+	path   TEXT PRIMARY KEY,
+	watch  TEXT NOT NULL,
+	size   INTEGER NOT NULL,
+	mode   TEXT NOT NULL,
+	uid    TEXT NOT NULL DEFAULT '',
+	gid    TEXT NOT NULL DEFAULT '',
+	mtime  INTEGER NOT NULL,
+	sha256 TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE IF NOT EXISTS baseline_scans (
+	id         INTEGER PRIMARY KEY CHECK (id = 1), -- only the last scan
+	roots      TEXT NOT NULL, -- JSON array
+	scanned_at INTEGER NOT NULL -- unix milliseconds
+);
+`
+
+// sqliteColumns are the columns added after the table was first created,
+// they are added to older databases on open
+var sqliteColumns = map[string]string{
+	"event_key":  "TEXT NOT NULL DEFAULT ''",
+	"fuzzy_hash": "TEXT NOT NULL DEFAULT ''",
+}
+
+const sqliteIndexes = `
+CREATE INDEX IF NOT EXISTS logs_created_at_id ON logs (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS logs_target_path ON logs (target_path);
+CREATE INDEX IF NOT EXISTS logs_action_created_at ON logs (action, created_at DESC);
+CREATE INDEX IF NOT EXISTS logs_event_time ON logs (event_time DESC);
+CREATE INDEX IF NOT EXISTS logs_md5 ON logs (md5) WHERE md5 != '';
+CREATE INDEX IF NOT EXISTS logs_sha256 ON logs (sha256) WHERE sha256 != '';
+CREATE UNIQUE INDEX IF NOT EXISTS logs_event_key ON logs (event_key) WHERE event_key != '';
+`
+
+// sqliteRegexps caches the patterns compiled by the regexp function, they
+// come from path_glob filters so there are few of them
+var sqliteRegexps sync.Map
+
+func init() {
+	// backs the REGEXP operator, which SQLite leaves undefined
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		pattern, _ := args[0].(string)
+		value, _ := args[1].(string)
+
+		re, ok := sqliteRegexps.Load(pattern)
+		if !ok {
+			compiled, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, err
+			}
+			re, _ = sqliteRegexps.LoadOrStore(pattern, compiled)
+		}
+
+		return re.(*regexp.Regexp).MatchString(value), nil
+	})
+}
+
+// sqliteDSN opens path as a file: URI with the path escaped, the driver
+// would otherwise take a ? or # in the path as the start of the parameters
+func sqliteDSN(path string) string {
+	slashed := filepath.ToSlash(path)
+	if filepath.IsAbs(path) && !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed // C:/logs.db is file:/C:/logs.db
+	}
+
+	return "file:" + (&url.URL{Path: slashed}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+}
+
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteLogStore opens or creates the SQLite database at path
+func NewSQLiteLogStore(ctx context.Context, path string) (LogStore, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log store directory: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", sqliteDSN(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite: %w", err)
+	}
+
+	err = migrateSQLite(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create sqlite schema: %w", err)
+	}
+
+	return &sqliteStore{
+		db: db,
+	}, nil
+}
+
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, sqliteTable+sqliteBaselineTables)
+	if err != nil {
+		return err
+	}
+
+	for column, definition := range sqliteColumns {
+		var exists bool
+		err := db.QueryRowContext(ctx, "SELECT COUNT(*) > 0 FROM pragma_table_info('logs') WHERE name = ?", column).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		_, err = db.ExecContext(ctx, "ALTER TABLE logs ADD COLUMN "+column+" "+definition)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.ExecContext(ctx, sqliteIndexes)
+	return err
+}
+
+// sqliteInsert ignores the events already logged, the ids are random so
+// event_key is the only constraint an insert can hit
+const sqliteInsert = `INSERT OR IGNORE INTO logs (id, created_at, event_time, log_time, target_path, action, md5, sha256, details, event_key, fuzzy_hash)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+func (l *sqliteStore) Write(ctx context.Context, logDetail map[string]string) (LogEntry, error) {
+	logEntries, err := l.WriteBatch(ctx, []map[string]string{logDetail})
+	if err != nil {
+		return LogEntry{}, err
+	}
+	if len(logEntries) == 0 {
+		return LogEntry{}, ErrDuplicateEvent
+	}
+
+	return logEntries[0], nil
+}
+
+func (l *sqliteStore) WriteBatch(ctx context.Context, logDetails []map[string]string) ([]LogEntry, error) {
+	if len(logDetails) == 0 {
+		return nil, nil
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	tx, err := l.db.BeginTx(ctxWithTimeout, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin sqlite transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctxWithTimeout, sqliteInsert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare log entry insert: %w", err)
+	}
+	defer stmt.Close()
+
+	logEntries := make([]LogEntry, 0, len(logDetails))
+	for _, logDetail := range logDetails {
+		logEntry := newLogEntry(logDetail)
+		details, err := json.Marshal(logEntry.Details)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode log details: %w", err)
+		}
+
+		res, err := stmt.ExecContext(ctxWithTimeout,
+			logEntry.ID, logEntry.CreatedAt.UnixMilli(), logEntry.EventTime.UnixMilli(), logEntry.LogTime,
+			logDetail["target_path"], logDetail["action"], logDetail["md5"], logEntry.SHA256, string(details),
+			logEntry.EventKey, logEntry.FuzzyHash,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert log entry into sqlite store: %w", err)
+		}
+		inserted, err := res.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert log entry into sqlite store: %w", err)
+		}
+		if inserted > 0 {
+			logEntries = append(logEntries, logEntry)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit log entries: %w", err)
+	}
+
+	return logEntries, nil
+}
+
+func (l *sqliteStore) ReadLogsPaginated(ctx context.Context, query LogQuery) (LogPage, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	limit := query.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	where, args := query.Filter.sqlWhere()
+	if query.Cursor != "" {
+		c, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return LogPage{}, err
+		}
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, c.CreatedAt.UnixMilli(), c.CreatedAt.UnixMilli(), c.ID)
+	}
+
+	// one extra to know whether there is a next page
+	logs, err := l.selectLogs(ctxWithTimeout, where, args, "created_at DESC, id DESC", limit+1, query.Offset)
+	if err != nil {
+		return LogPage{}, err
+	}
+
+	page := LogPage{Items: logs}
+	if int64(len(logs)) > limit {
+		page.Items = logs[:limit]
+		page.NextCursor = page.Items[limit-1].Cursor()
+	}
+
+	where, args = query.Filter.sqlWhere()
+	err = l.db.QueryRowContext(ctxWithTimeout, "SELECT COUNT(*) FROM logs"+sqlWhereClause(where), args...).Scan(&page.Total)
+	if err != nil {
+		return LogPage{}, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	return page, nil
+}
+
+func (l *sqliteStore) ReadLogsAfter(ctx context.Context, query LogQuery) ([]LogEntry, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, 1*time.Minute)
+	defer cancel()
+
+	limit := query.Limit
+	if limit < 1 {
+		limit = 10
+	}
+
+	c, err := decodeCursor(query.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := query.Filter.sqlWhere()
+	where = append(where, "(created_at > ? OR (created_at = ? AND id > ?))")
+	args = append(args, c.CreatedAt.UnixMilli(), c.CreatedAt.UnixMilli(), c.ID)
+
+	return l.selectLogs(ctxWithTimeout, where, args, "created_at, id", limit, 0)
+}
+
+func (l *sqliteStore) selectLogs(ctx context.Context, where []string, args []interface{}, orderBy string, limit, offset int64) ([]LogEntry, error) {
+	rows, err := l.db.QueryContext(ctx,
+		"SELECT id, created_at, event_time, log_time, details, event_key, sha256, fuzzy_hash FROM logs"+sqlWhereClause(where)+
+			" ORDER BY "+orderBy+" LIMIT ? OFFSET ?",
+		append(args, limit, offset)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch logs: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		var createdAt, eventTime int64
+		var details string
+		err := rows.Scan(&entry.ID, &createdAt, &eventTime, &entry.LogTime, &details, &entry.EventKey, &entry.SHA256, &entry.FuzzyHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode logs: %w", err)
+		}
+		err = json.Unmarshal([]byte(details), &entry.Details)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode log details: %w", err)
+		}
+		entry.CreatedAt = time.UnixMilli(createdAt).UTC()
+		entry.EventTime = time.UnixMilli(eventTime).UTC()
+		logs = append(logs, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to fetch logs: %w", err)
+	}
+
+	return logs, nil
+}
+
+func (l *sqliteStore) LoadBaseline(ctx context.Context) (Baseline, bool, error) {
+	var roots string
+	var scannedAt int64
+	err := l.db.QueryRowContext(ctx, "SELECT roots, scanned_at FROM baseline_scans WHERE id = 1").Scan(&roots, &scannedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Baseline{}, false, nil
+	}
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+
+	baseline := Baseline{
+		Files:     make(map[string]FileState),
+		ScannedAt: time.UnixMilli(scannedAt).UTC(),
+	}
+	err = json.Unmarshal([]byte(roots), &baseline.Roots)
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to decode baseline roots: %w", err)
+	}
+
+	rows, err := l.db.QueryContext(ctx, "SELECT path, watch, size, mode, uid, gid, mtime, sha256 FROM baseline_files")
+	if err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to load baseline: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var path string
+		var state FileState
+		err = rows.Scan(&path, &state.Watch, &state.Size, &state.Mode, &state.UID, &state.GID, &state.MTime, &state.SHA256)
+		if err != nil {
+			return Baseline{}, false, fmt.Errorf("failed to read baseline: %w", err)
+		}
+		baseline.Files[path] = state
+	}
+	if err = rows.Err(); err != nil {
+		return Baseline{}, false, fmt.Errorf("failed to read baseline: %w", err)
+	}
+
+	return baseline, true, nil
+}
+
+// UpdateBaseline writes the update in one transaction, a failed update
+// leaves the previous baseline as it was
+func (l *sqliteStore) UpdateBaseline(ctx context.Context, update BaselineUpdate) error {
+	roots, err := json.Marshal(update.Roots)
+	if err != nil {
+		return fmt.Errorf("failed to encode baseline roots: %w", err)
+	}
+
+	tx, err := l.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin sqlite transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if update.Replace {
+		_, err = tx.ExecContext(ctx, "DELETE FROM baseline_files")
+		if err != nil {
+			return fmt.Errorf("failed to clear baseline: %w", err)
+		}
+	}
+
+	upsert, err := tx.PrepareContext(ctx, `INSERT OR REPLACE INTO baseline_files (path, watch, size, mode, uid, gid, mtime, sha256)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare baseline update: %w", err)
+	}
+	defer upsert.Close()
+	for path, state := range update.Set {
+		_, err = upsert.ExecContext(ctx, path, state.Watch, state.Size, state.Mode, state.UID, state.GID, state.MTime, state.SHA256)
+		if err != nil {
+			return fmt.Errorf("failed to update baseline: %w", err)
+		}
+	}
+
+	for _, path := range update.Deleted {
+		_, err = tx.ExecContext(ctx, "DELETE FROM baseline_files WHERE path = ?", path)
+		if err != nil {
+			return fmt.Errorf("failed to update baseline: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT OR REPLACE INTO baseline_scans (id, roots, scanned_at) VALUES (1, ?, ?)",
+		string(roots), update.ScannedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to update baseline: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit baseline update: %w", err)
+	}
+
+	return nil
+}
+
+func (l *sqliteStore) Close(ctx context.Context) error {
+	return l.db.Close()
+}
+
+// sqlWhere translates the filter into conditions on the indexed columns
+func (f LogFilter) sqlWhere() ([]string, []interface{}) {
+	var where []string
+	var args []interface{}
+
+	if f.PathPrefix != "" {
+		// GLOB is case sensitive and uses the target_path index, unlike LIKE
+		where = append(where, "target_path GLOB ?")
+		args = append(args, escapeSQLiteGlob(f.PathPrefix)+"*")
+	}
+	if f.PathGlob != "" {
+		where = append(where, "target_path REGEXP ?")
+		args = append(args, globToRegex(f.PathGlob))
+	}
+
+	if len(f.Actions) > 0 {
+		where = append(where, "action IN (?"+strings.Repeat(", ?", len(f.Actions)-1)+")")
+		for _, action := range f.Actions {
+			args = append(args, action)
+		}
+	}
+
+	if !f.Since.IsZero() {
+		where = append(where, "event_time >= ?")
+		args = append(args, f.Since.UnixMilli())
+	}
+	if !f.Until.IsZero() {
+		where = append(where, "event_time < ?")
+		args = append(args, f.Until.UnixMilli())
+	}
+
+	if f.MD5 != "" {
+		where = append(where, "md5 = ?")
+		args = append(args, f.MD5)
+	}
+	if f.SHA256 != "" {
+		where = append(where, "sha256 = ?")
+		args = append(args, f.SHA256)
+	}
+
+	return where, args
+}
+
+func sqlWhereClause(where []string) string {
+	if len(where) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(where, " AND ")
+}
+
+// escapeSQLiteGlob makes the GLOB wildcards in s match themselves
+func escapeSQLiteGlob(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		switch c {
+		case '*', '?', '[':
+			b.WriteRune('[')
+			b.WriteRune(c)
+			b.WriteRune(']')
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
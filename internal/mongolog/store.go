@@ -0,0 +1,30 @@
+package mongolog
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+)
+
+// NewLogStore opens the log store selected by log_store.driver, the file
+// stores default to a file in the data directory
+func NewLogStore(ctx context.Context, cfg *config.Config) (LogStore, error) {
+	switch cfg.LogStoreDriver {
+	case config.LogStoreSQLite:
+		return NewSQLiteLogStore(ctx, logStorePath(cfg, "logs.db"))
+	case config.LogStoreJSONL:
+		return NewJSONLLogStore(logStorePath(cfg, "logs.jsonl"))
+	case config.LogStoreMemory:
+		return NewMemoryLogStore(cfg.LogStoreCapacity), nil
+	default:
+		return NewMongoLogStore(ctx, cfg.MongoURI, config.LogsDBName, config.LogsCollectionName)
+	}
+}
+
+func logStorePath(cfg *config.Config, name string) string {
+	if cfg.LogStorePath != "" {
+		return cfg.LogStorePath
+	}
+	return filepath.Join(cfg.DataDir, name)
+}
@@ -0,0 +1,229 @@
+package reporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/danielboakye/filechangestracker/pkg/atomicfile"
+)
+
+// compactAfter is how many lines of the outbox file may describe events no
+// longer pending before it is rewritten with only the pending ones
+const compactAfter = 1024
+
+// outbox is an ordered queue of undelivered events. Every event gets the
+// next sequence number, delivered or trimmed events are removed up to a
+// sequence number so events appended meanwhile are never removed in their
+// place. When path is set, appends and removals are appended to the file as
+// lines so pending events survive restarts.
+type outbox struct {
+	mu     sync.Mutex
+	path   string
+	limit  int
+	file   *os.File
+	events []outboxEvent // oldest first
+	next   uint64        // sequence number of the next event
+	dead   int           // lines of the file that describe no pending event
+}
+
+type outboxEvent struct {
+	Seq   uint64            `json:"seq"`
+	Event map[string]string `json:"event"`
+}
+
+// outboxRecord is a line of the outbox file: an event added or the events
+// removed up to and including Through
+type outboxRecord struct {
+	Add     *outboxEvent `json:"add,omitempty"`
+	Through uint64       `json:"through,omitempty"`
+}
+
+func openOutbox(path string, limit int) (*outbox, error) {
+	o := &outbox{
+		path:  path,
+		limit: limit,
+		next:  1,
+	}
+	if path == "" {
+		return o, nil
+	}
+
+	err := o.load()
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.compact()
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// load replays the outbox file, a line cut short by a crash ends it
+func (o *outbox) load() error {
+	file, err := os.Open(o.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("error reading outbox: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record outboxRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			break
+		}
+		if record.Add != nil {
+			o.events = append(o.events, *record.Add)
+			o.next = record.Add.Seq + 1
+		}
+		if record.Through > 0 {
+			o.removeThrough(record.Through)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading outbox: %w", err)
+	}
+
+	return nil
+}
+
+// append adds events to the end of the outbox and returns how many of the
+// oldest events were dropped to stay within the limit.
+func (o *outbox) append(events []map[string]string) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	records := make([]outboxRecord, 0, len(events)+1)
+	for _, event := range events {
+		added := outboxEvent{Seq: o.next, Event: event}
+		o.next++
+		o.events = append(o.events, added)
+		records = append(records, outboxRecord{Add: &added})
+	}
+
+	dropped := 0
+	if o.limit > 0 && len(o.events) > o.limit {
+		dropped = len(o.events) - o.limit
+		through := o.events[dropped-1].Seq
+		o.removeThrough(through)
+		records = append(records, outboxRecord{Through: through})
+	}
+
+	return dropped, o.write(records)
+}
+
+// peek returns up to n of the oldest events without removing them, and the
+// sequence number of the last one to remove them by once delivered.
+func (o *outbox) peek(n int) (batch []map[string]string, through uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if n > len(o.events) {
+		n = len(o.events)
+	}
+
+	batch = make([]map[string]string, 0, n)
+	for _, event := range o.events[:n] {
+		batch = append(batch, event.Event)
+		through = event.Seq
+	}
+	return batch, through
+}
+
+// remove drops the events up to and including sequence number through,
+// typically after they were delivered. Events trimmed meanwhile are already
+// gone and the ones appended after through are kept.
+func (o *outbox) remove(through uint64) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if len(o.events) == 0 || o.events[0].Seq > through {
+		return nil
+	}
+	o.removeThrough(through)
+
+	return o.write([]outboxRecord{{Through: through}})
+}
+
+func (o *outbox) removeThrough(through uint64) {
+	n := 0
+	for n < len(o.events) && o.events[n].Seq <= through {
+		n++
+	}
+	o.events = o.events[n:]
+	o.dead += n + 1
+}
+
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return len(o.events)
+}
+
+// write appends records to the outbox file, and rewrites the file once
+// enough of it describes events no longer pending. The caller must hold mu.
+func (o *outbox) write(records []outboxRecord) error {
+	if o.path == "" || len(records) == 0 {
+		return nil
+	}
+	if o.file == nil || (o.dead >= compactAfter && o.dead > len(o.events)) {
+		return o.compact()
+	}
+
+	var data []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("error encoding outbox: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	_, err := o.file.Write(data)
+	if err != nil {
+		return fmt.Errorf("error writing outbox: %w", err)
+	}
+
+	return nil
+}
+
+// compact rewrites the outbox file with a line per pending event and
+// reopens it for appending
+func (o *outbox) compact() error {
+	var data []byte
+	for i := range o.events {
+		line, err := json.Marshal(outboxRecord{Add: &o.events[i]})
+		if err != nil {
+			return fmt.Errorf("error encoding outbox: %w", err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+
+	err := atomicfile.WriteFile(o.path, data, 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing outbox: %w", err)
+	}
+
+	if o.file != nil {
+		o.file.Close()
+	}
+	o.file, err = os.OpenFile(o.path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening outbox: %w", err)
+	}
+	o.dead = 0
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package reporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+)
+
+const (
+	outboxFileName = "outbox.jsonl"
+	requestTimeout = 30 * time.Second
+)
+
+//go:generate mockgen -destination=../../mocks/reporter/mock_reporter.go -package=reportermock -source=reporter.go
+type Reporter interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+
+	Enqueue(events []map[string]string) error
+	Status() DeliveryStatus
+}
+
+// DeliveryStatus describes the state of delivery to the reporting api
+type DeliveryStatus struct {
+	Pending             int        `json:"pending"`
+	Delivered           int64      `json:"delivered"`
+	Dropped             int64      `json:"dropped"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastAttemptAt       *time.Time `json:"last_attempt_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	NextAttemptAt       *time.Time `json:"next_attempt_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+// Payload is the JSON body posted to the reporting api
+type Payload struct {
+	Events []map[string]string `json:"events"`
+}
+
+type reporter struct {
+	appLogger  *slog.Logger
+	config     *config.Config
+	httpClient *http.Client
+	outbox     *outbox
+	notify     chan struct{}
+
+	mu     sync.Mutex
+	status DeliveryStatus
+}
+
+func New(appLogger *slog.Logger, cfg *config.Config) (Reporter, error) {
+	outboxPath := ""
+	if cfg.DataDir != "" {
+		outboxPath = filepath.Join(cfg.DataDir, outboxFileName)
+	}
+
+	box, err := openOutbox(outboxPath, cfg.ReportingOutboxLimit)
+	if err != nil {
+		return nil, fmt.Errorf("error opening outbox: %w", err)
+	}
+
+	return &reporter{
+		appLogger:  appLogger,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: requestTimeout},
+		outbox:     box,
+		notify:     make(chan struct{}, 1),
+	}, nil
+}
+
+func (r *reporter) Start(ctx context.Context) error {
+	go r.deliveryThread(ctx)
+
+	return nil
+}
+
+func (r *reporter) Stop(ctx context.Context) error {
+	return nil
+}
+
+// Enqueue stores events in the outbox; they are delivered asynchronously.
+func (r *reporter) Enqueue(events []map[string]string) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	dropped, err := r.outbox.append(events)
+	if dropped > 0 {
+		r.mu.Lock()
+		r.status.Dropped += int64(dropped)
+		r.mu.Unlock()
+		r.appLogger.Warn("reporter-outbox-full", slog.Int("dropped", dropped))
+	}
+	if err != nil {
+		return fmt.Errorf("error adding events to outbox: %w", err)
+	}
+
+	if r.outbox.len() >= r.config.ReportingBatchSize {
+		select {
+		case r.notify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (r *reporter) Status() DeliveryStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status := r.status
+	status.Pending = r.outbox.len()
+	return status
+}
+
+func (r *reporter) deliveryThread(ctx context.Context) {
+	interval := time.Duration(r.config.ReportingInterval) * time.Second
+	wait := interval
+	for {
+		select {
+		case <-ctx.Done():
+			r.appLogger.Info("reporter-shutdown")
+			return
+		case <-time.After(wait):
+		case <-r.notify:
+			if remaining := r.backoffRemaining(); remaining > 0 {
+				wait = remaining
+				continue
+			}
+		}
+
+		wait = interval
+		if err := r.flush(ctx); err != nil {
+			wait = r.nextBackoff()
+			r.appLogger.Error("error-delivering-events", slog.String("error", err.Error()), slog.Duration("retry_in", wait))
+		}
+	}
+}
+
+// flush delivers the outbox in batches until it is empty or a delivery fails.
+func (r *reporter) flush(ctx context.Context) error {
+	for {
+		batch, through := r.outbox.peek(r.config.ReportingBatchSize)
+		if len(batch) == 0 {
+			return nil
+		}
+
+		err := r.deliver(ctx, batch)
+		r.recordAttempt(len(batch), err)
+		if err != nil {
+			return err
+		}
+
+		// events trimmed from the outbox while the batch was out are gone
+		// already, removing by sequence number keeps newer ones
+		err = r.outbox.remove(through)
+		if err != nil {
+			return fmt.Errorf("error removing delivered events from outbox: %w", err)
+		}
+	}
+}
+
+func (r *reporter) deliver(ctx context.Context, events []map[string]string) error {
+	body, err := json.Marshal(Payload{Events: events})
+	if err != nil {
+		return fmt.Errorf("error encoding events: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.config.ReportingAPI, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting events: %w", err)
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("reporting api responded with status: %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (r *reporter) recordAttempt(delivered int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.status.LastAttemptAt = &now
+	r.status.NextAttemptAt = nil
+	if err != nil {
+		r.status.ConsecutiveFailures++
+		r.status.LastError = err.Error()
+		return
+	}
+
+	r.status.ConsecutiveFailures = 0
+	r.status.LastError = ""
+	r.status.LastSuccessAt = &now
+	r.status.Delivered += int64(delivered)
+}
+
+// nextBackoff doubles the retry delay with every consecutive failure,
+// starting at the reporting interval and capped at the max backoff.
+func (r *reporter) nextBackoff() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wait := backoff(
+		r.status.ConsecutiveFailures,
+		time.Duration(r.config.ReportingInterval)*time.Second,
+		time.Duration(r.config.ReportingMaxBackoff)*time.Second,
+	)
+	next := time.Now().Add(wait)
+	r.status.NextAttemptAt = &next
+
+	return wait
+}
+
+// backoffRemaining reports how long until the next retry is due, so a full
+// batch does not cut a backoff short.
+func (r *reporter) backoffRemaining() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.status.NextAttemptAt == nil {
+		return 0
+	}
+
+	return time.Until(*r.status.NextAttemptAt)
+}
+
+func backoff(failures int, base, max time.Duration) time.Duration {
+	wait := base
+	for i := 1; i < failures && wait < max; i++ {
+		wait *= 2
+	}
+	if wait > max {
+		wait = max
+	}
+
+	return wait
+}
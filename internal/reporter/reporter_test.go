@@ -0,0 +1,298 @@
+package reporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover ./internal/reporter/...
+
+type reportingAPI struct {
+	mu       sync.Mutex
+	fail     bool
+	payloads []Payload
+}
+
+func (a *reportingAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.fail {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var payload Payload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	a.payloads = append(a.payloads, payload)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *reportingAPI) setFail(fail bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fail = fail
+}
+
+func newTestReporter(t *testing.T, apiURL, dataDir string) *reporter {
+	cfg := &config.Config{
+		ReportingAPI:         apiURL,
+		DataDir:              dataDir,
+		ReportingBatchSize:   2,
+		ReportingInterval:    1,
+		ReportingMaxBackoff:  4,
+		ReportingOutboxLimit: 100,
+	}
+
+	r, err := New(slog.Default(), cfg)
+	require.NoError(t, err)
+
+	return r.(*reporter)
+}
+
+func testEvents(paths ...string) []map[string]string {
+	events := make([]map[string]string, 0, len(paths))
+	for _, p := range paths {
+		events = append(events, map[string]string{
+			"target_path": p,
+			"action":      "CREATED",
+		})
+	}
+	return events
+}
+
+// go test -v -cover -run TestFlush ./internal/reporter
+func TestFlush(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	api := &reportingAPI{}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	r := newTestReporter(t, server.URL, t.TempDir())
+
+	err := r.Enqueue(testEvents("/tmp/a", "/tmp/b", "/tmp/c"))
+	require.NoError(err)
+
+	err = r.flush(context.Background())
+	require.NoError(err)
+
+	assert.Len(api.payloads, 2) // batch size of 2
+	assert.Len(api.payloads[0].Events, 2)
+	assert.Equal("/tmp/c", api.payloads[1].Events[0]["target_path"])
+
+	status := r.Status()
+	assert.Equal(0, status.Pending)
+	assert.Equal(int64(3), status.Delivered)
+	assert.Equal(0, status.ConsecutiveFailures)
+	assert.NotNil(status.LastSuccessAt)
+}
+
+// go test -v -cover -run TestFlush_Retry ./internal/reporter
+func TestFlush_Retry(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	api := &reportingAPI{fail: true}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	r := newTestReporter(t, server.URL, t.TempDir())
+
+	err := r.Enqueue(testEvents("/tmp/a"))
+	require.NoError(err)
+
+	err = r.flush(context.Background())
+	assert.Error(err)
+	assert.Equal(time.Second, r.nextBackoff())
+
+	status := r.Status()
+	assert.NotNil(status.NextAttemptAt)
+	assert.Equal(1, status.Pending)
+	assert.Equal(1, status.ConsecutiveFailures)
+	assert.Contains(status.LastError, "503")
+
+	api.setFail(false)
+	err = r.flush(context.Background())
+	require.NoError(err)
+
+	status = r.Status()
+	assert.Equal(0, status.Pending)
+	assert.Equal(0, status.ConsecutiveFailures)
+	assert.Empty(status.LastError)
+	assert.Len(api.payloads, 1)
+}
+
+// go test -v -cover -run TestOutbox_SurvivesRestart ./internal/reporter
+func TestOutbox_SurvivesRestart(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	api := &reportingAPI{fail: true}
+	server := httptest.NewServer(api)
+	defer server.Close()
+
+	dataDir := t.TempDir()
+	r := newTestReporter(t, server.URL, dataDir)
+
+	err := r.Enqueue(testEvents("/tmp/a", "/tmp/b"))
+	require.NoError(err)
+	assert.Error(r.flush(context.Background()))
+
+	api.setFail(false)
+	restarted := newTestReporter(t, server.URL, dataDir)
+	assert.Equal(2, restarted.Status().Pending)
+
+	err = restarted.flush(context.Background())
+	require.NoError(err)
+	assert.Equal(0, restarted.Status().Pending)
+	require.Len(api.payloads, 1)
+	assert.Equal("/tmp/b", api.payloads[0].Events[1]["target_path"])
+}
+
+// go test -v -cover -run TestOutbox_Limit ./internal/reporter
+func TestOutbox_Limit(t *testing.T) {
+	assert := assert.New(t)
+
+	box, err := openOutbox("", 2)
+	assert.NoError(err)
+
+	dropped, err := box.append(testEvents("/tmp/a", "/tmp/b", "/tmp/c"))
+	assert.NoError(err)
+	assert.Equal(1, dropped)
+	batch, _ := box.peek(1)
+	assert.Equal("/tmp/b", batch[0]["target_path"])
+}
+
+// go test -v -cover -run TestOutbox_TrimmedDuringDelivery ./internal/reporter
+func TestOutbox_TrimmedDuringDelivery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), outboxFileName)
+	box, err := openOutbox(path, 3)
+	require.NoError(err)
+
+	_, err = box.append(testEvents("/tmp/a", "/tmp/b"))
+	require.NoError(err)
+	batch, through := box.peek(2)
+	require.Len(batch, 2)
+
+	// appended past the limit while the batch is being delivered, a and b
+	// are trimmed and c, d, e are kept
+	dropped, err := box.append(testEvents("/tmp/c", "/tmp/d", "/tmp/e"))
+	require.NoError(err)
+	assert.Equal(2, dropped)
+
+	require.NoError(box.remove(through))
+	pending, _ := box.peek(10)
+	assert.Equal(testEvents("/tmp/c", "/tmp/d", "/tmp/e"), pending)
+
+	reopened, err := openOutbox(path, 3)
+	require.NoError(err)
+	pending, _ = reopened.peek(10)
+	assert.Equal(testEvents("/tmp/c", "/tmp/d", "/tmp/e"), pending)
+}
+
+// go test -v -cover -run TestFlush_TrimmedDuringDelivery ./internal/reporter
+func TestFlush_TrimmedDuringDelivery(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	received := make(chan struct{})
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := newTestReporter(t, server.URL, t.TempDir())
+	r.config.ReportingOutboxLimit = 3
+	r.outbox.limit = 3
+	require.NoError(r.Enqueue(testEvents("/tmp/a", "/tmp/b")))
+
+	flushed := make(chan error, 1)
+	go func() { flushed <- r.flush(context.Background()) }()
+
+	// a and b are trimmed while their delivery is in flight
+	<-received
+	require.NoError(r.Enqueue(testEvents("/tmp/c", "/tmp/d", "/tmp/e")))
+	close(release)
+
+	// c, d and e are delivered next, none is lost to the removal of a and b
+	for i := 0; i < 2; i++ {
+		<-received
+	}
+	require.NoError(<-flushed)
+	assert.Equal(0, r.Status().Pending)
+	assert.Equal(int64(5), r.Status().Delivered)
+}
+
+// go test -v -cover -run TestOutbox_Compacts ./internal/reporter
+func TestOutbox_Compacts(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	path := filepath.Join(t.TempDir(), outboxFileName)
+	box, err := openOutbox(path, 0)
+	require.NoError(err)
+
+	for i := 0; i < compactAfter; i++ {
+		_, err = box.append(testEvents(fmt.Sprintf("/tmp/%d", i)))
+		require.NoError(err)
+		_, through := box.peek(1)
+		require.NoError(box.remove(through))
+	}
+	_, err = box.append(testEvents("/tmp/last"))
+	require.NoError(err)
+
+	// delivered events are not kept in the file for good
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.Less(strings.Count(string(data), "\n"), compactAfter)
+
+	reopened, err := openOutbox(path, 0)
+	require.NoError(err)
+	pending, _ := reopened.peek(10)
+	assert.Equal(testEvents("/tmp/last"), pending)
+}
+
+// go test -v -cover -run TestBackoff ./internal/reporter
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		expected time.Duration
+	}{
+		{"First failure", 1, time.Second},
+		{"Second failure", 2, 2 * time.Second},
+		{"Third failure", 3, 4 * time.Second},
+		{"Capped at max", 10, 8 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, backoff(tt.failures, time.Second, 8*time.Second))
+		})
+	}
+}
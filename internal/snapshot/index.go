@@ -0,0 +1,295 @@
+package snapshot
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/pkg/atomicfile"
+)
+
+const (
+	indexFileName = "index.jsonl"
+
+	// pruneInterval is how often max_age is applied, max_versions and
+	// max_total_size are applied as soon as a capture goes over them
+	pruneInterval = time.Hour
+)
+
+// indexRecord is a line of the index: a version captured or the versions
+// dropped by pruning. The index is only appended to while the store is
+// open and compacted to one add per version on open.
+type indexRecord struct {
+	Add  *Version     `json:"add,omitempty"`
+	Drop []versionRef `json:"drop,omitempty"`
+}
+
+type versionRef struct {
+	Path string `json:"path"`
+	ID   string `json:"id"`
+}
+
+// loadIndex reads the versions kept by path, none when the store is new.
+// A line cut short by a crash ends the index, what it recorded is lost.
+func loadIndex(dir string) (map[string][]Version, error) {
+	versions := make(map[string][]Version)
+
+	file, err := os.Open(filepath.Join(dir, indexFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return versions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshot index: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var record indexRecord
+		if json.Unmarshal(scanner.Bytes(), &record) != nil {
+			break
+		}
+		if record.Add != nil {
+			versions[record.Add.Path] = append(versions[record.Add.Path], *record.Add)
+		}
+		for _, ref := range record.Drop {
+			kept := versions[ref.Path][:0]
+			for _, version := range versions[ref.Path] {
+				if version.ID != ref.ID {
+					kept = append(kept, version)
+				}
+			}
+			if len(kept) == 0 {
+				delete(versions, ref.Path)
+				continue
+			}
+			versions[ref.Path] = kept
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading snapshot index: %w", err)
+	}
+
+	return versions, nil
+}
+
+// openIndex replaces the index with a line per version and opens it for
+// appending
+func openIndex(dir string, versions map[string][]Version) (*os.File, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, pathVersions := range versions {
+		for i := range pathVersions {
+			err := encoder.Encode(indexRecord{Add: &pathVersions[i]})
+			if err != nil {
+				return nil, fmt.Errorf("error encoding snapshot index: %w", err)
+			}
+		}
+	}
+
+	path := filepath.Join(dir, indexFileName)
+	err := atomicfile.WriteFile(path, buf.Bytes(), 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error saving snapshot index: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot index: %w", err)
+	}
+
+	return file, nil
+}
+
+// appendIndex writes record as a line of the index. The caller must hold mu.
+func (s *store) appendIndex(record indexRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot index: %w", err)
+	}
+
+	_, err = s.index.Write(append(data, '\n'))
+	if err != nil {
+		return fmt.Errorf("error saving snapshot index: %w", err)
+	}
+
+	return nil
+}
+
+// add keeps version as the newest of its path. The caller must hold mu.
+func (s *store) add(version Version) error {
+	err := s.appendIndex(indexRecord{Add: &version})
+	if err != nil {
+		return err
+	}
+
+	s.versions[version.Path] = append(s.versions[version.Path], version)
+	s.retain(version)
+
+	return nil
+}
+
+// retain and release count the versions using each content, total is the
+// size of the contents in use
+func (s *store) retain(version Version) {
+	if s.refs[version.SHA256] == 0 {
+		s.total += version.Size
+	}
+	s.refs[version.SHA256]++
+}
+
+func (s *store) release(version Version) (unused bool) {
+	s.refs[version.SHA256]--
+	if s.refs[version.SHA256] > 0 {
+		return false
+	}
+
+	delete(s.refs, version.SHA256)
+	s.total -= version.Size
+	return true
+}
+
+// prune applies the retention limits to every file, on Start and then
+// every pruneInterval. The newest version of a file is kept past max_age,
+// it is what a later change is compared and restored against. The caller
+// must hold mu.
+func (s *store) prune(now time.Time) error {
+	dropped := make(map[string]bool)
+	for _, versions := range s.versions {
+		s.overLimits(versions, now, dropped)
+	}
+	s.overTotalSize(dropped)
+
+	return s.drop(dropped)
+}
+
+// limit drops what a capture of path put over max_versions or
+// max_total_size, max_age is left to prune. The caller must hold mu.
+func (s *store) limit(path string) error {
+	dropped := make(map[string]bool)
+	s.overLimits(s.versions[path], time.Time{}, dropped)
+	s.overTotalSize(dropped)
+
+	return s.drop(dropped)
+}
+
+// overLimits adds the versions past max_versions to dropped and, unless
+// now is zero, the ones older than max_age
+func (s *store) overLimits(versions []Version, now time.Time, dropped map[string]bool) {
+	if n := s.config.SnapshotMaxVersions; n > 0 && len(versions) > n {
+		for _, version := range versions[:len(versions)-n] {
+			dropped[version.ID] = true
+		}
+	}
+
+	maxAge := time.Duration(s.config.SnapshotMaxAge) * time.Second
+	if maxAge > 0 && !now.IsZero() && len(versions) > 0 {
+		for _, version := range versions[:len(versions)-1] {
+			if now.Sub(version.CapturedAt) > maxAge {
+				dropped[version.ID] = true
+			}
+		}
+	}
+}
+
+// overTotalSize adds the oldest versions across all files to dropped until
+// the contents left fit in max_total_size
+func (s *store) overTotalSize(dropped map[string]bool) {
+	maxTotal := s.config.SnapshotMaxTotalSize
+	if maxTotal <= 0 || s.total <= maxTotal {
+		return
+	}
+
+	refs := make(map[string]int, len(s.refs))
+	for sha, n := range s.refs {
+		refs[sha] = n
+	}
+	total := s.total
+	var all []Version
+	for _, versions := range s.versions {
+		for _, version := range versions {
+			if !dropped[version.ID] {
+				all = append(all, version)
+				continue
+			}
+			refs[version.SHA256]--
+			if refs[version.SHA256] == 0 {
+				total -= version.Size
+			}
+		}
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CapturedAt.Before(all[j].CapturedAt)
+	})
+	for _, version := range all {
+		if total <= maxTotal {
+			break
+		}
+		dropped[version.ID] = true
+		refs[version.SHA256]--
+		if refs[version.SHA256] == 0 {
+			total -= version.Size
+		}
+	}
+}
+
+// drop removes the versions by ID from the index, then the contents no
+// version uses anymore. The caller must hold mu.
+func (s *store) drop(dropped map[string]bool) error {
+	if len(dropped) == 0 {
+		return nil
+	}
+
+	var record indexRecord
+	for path, versions := range s.versions {
+		for _, version := range versions {
+			if dropped[version.ID] {
+				record.Drop = append(record.Drop, versionRef{Path: path, ID: version.ID})
+			}
+		}
+	}
+
+	// the index goes first, a crash in between leaves contents no version
+	// uses rather than versions without their contents
+	err := s.appendIndex(record)
+	if err != nil {
+		return err
+	}
+
+	var unused []string
+	for path, versions := range s.versions {
+		kept := versions[:0]
+		for _, version := range versions {
+			if !dropped[version.ID] {
+				kept = append(kept, version)
+				continue
+			}
+			if s.release(version) {
+				unused = append(unused, version.SHA256)
+			}
+		}
+		if len(kept) == 0 {
+			delete(s.versions, path)
+			continue
+		}
+		s.versions[path] = kept
+	}
+
+	for _, sha := range unused {
+		err := os.Remove(s.blobPath(sha))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("error removing snapshot: %w", err)
+		}
+	}
+
+	return nil
+}
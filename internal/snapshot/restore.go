@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// Diff returns the unified diff of path from version from to version to,
+// an empty to compares with the file as it is now
+func (s *store) Diff(path, from, to string) (string, error) {
+	fromVersion, err := s.version(path, from)
+	if err != nil {
+		return "", err
+	}
+	a, err := s.readText(s.blobPath(fromVersion.SHA256))
+	if err != nil {
+		return "", err
+	}
+
+	toName := path + " (current)"
+	toDate := ""
+	var b string
+	if to == "" {
+		b, err = s.readText(path)
+	} else {
+		var toVersion Version
+		toVersion, err = s.version(path, to)
+		if err == nil {
+			toName = path + "@" + toVersion.ID
+			toDate = toVersion.CapturedAt.Format(time.RFC3339)
+			b, err = s.readText(s.blobPath(toVersion.SHA256))
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a),
+		B:        difflib.SplitLines(b),
+		FromFile: path + "@" + fromVersion.ID,
+		FromDate: fromVersion.CapturedAt.Format(time.RFC3339),
+		ToFile:   toName,
+		ToDate:   toDate,
+		Context:  diffContext,
+	})
+}
+
+// readText reads a file for diffing, it must be valid UTF-8 without NUL
+// bytes and within the snapshot size limit
+func (s *store) readText(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var src io.Reader = file
+	maxSize := s.config.SnapshotMaxFileSize
+	if maxSize > 0 {
+		src = io.LimitReader(file, maxSize+1)
+	}
+	data, err := io.ReadAll(src)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", path, err)
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return "", fmt.Errorf("%w: %s is larger than snapshots.max_file_size", ErrNotText, path)
+	}
+	if bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data) {
+		return "", ErrNotText
+	}
+
+	return string(data), nil
+}
+
+// Restore writes version id back to path. The path goes through the same
+// checks as command path arguments, and the file is replaced in one rename
+// so readers never see it half written.
+func (s *store) Restore(path, id string) (Version, error) {
+	version, err := s.version(path, id)
+	if err != nil {
+		return Version{}, err
+	}
+
+	err = s.paths.CheckPath(path)
+	if err != nil {
+		return Version{}, err
+	}
+
+	blob, err := os.Open(s.blobPath(version.SHA256))
+	if err != nil {
+		return Version{}, fmt.Errorf("error opening version %s: %w", id, err)
+	}
+	defer blob.Close()
+
+	// restoring a file of a directory deleted since brings the directory back
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".restore-*")
+	if err != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hash), blob)
+	closeErr := tmp.Close()
+	if err != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, err)
+	}
+	if closeErr != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, closeErr)
+	}
+	if sha := hex.EncodeToString(hash.Sum(nil)); sha != version.SHA256 {
+		return Version{}, fmt.Errorf("error restoring %s: version %s is corrupted, its sha256 is %s", path, id, sha)
+	}
+
+	mode, err := strconv.ParseUint(version.Mode, 8, 32)
+	if err == nil {
+		err = os.Chmod(tmp.Name(), os.FileMode(mode))
+	}
+	if err != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, err)
+	}
+
+	err = os.Rename(tmp.Name(), path)
+	if err != nil {
+		return Version{}, fmt.Errorf("error restoring %s: %w", path, err)
+	}
+
+	s.appLogger.Info("restored-file-version", slog.String("path", path), slog.String("version", id))
+
+	return version, nil
+}
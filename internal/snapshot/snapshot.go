@@ -0,0 +1,310 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/google/uuid"
+)
+
+//go:generate mockgen -destination=../../mocks/snapshot/mock_snapshot.go -package=snapshotmock -source=snapshot.go
+type Store interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
+
+	Versions(path string) ([]Version, error)
+	Diff(path, from, to string) (string, error)
+	Restore(path, id string) (Version, error)
+}
+
+var (
+	ErrVersionNotFound = errors.New("version not found")
+	ErrNotText         = errors.New("only text files can be diffed")
+)
+
+// captureActions are the actions after which the file has content to copy
+var captureActions = map[string]bool{
+	"CREATED":  true,
+	"UPDATED":  true,
+	"MOVED_TO": true,
+}
+
+// PathChecker rejects restores to paths commands may not write to, it is
+// implemented by commandexecutor.CommandExecutor
+type PathChecker interface {
+	CheckPath(path string) error
+}
+
+// Version is a copy of a file as it was after a change. Contents are
+// stored once per sha256 however many versions share them.
+type Version struct {
+	ID         string    `json:"id"`
+	Path       string    `json:"path"`
+	SHA256     string    `json:"sha256"`
+	Size       int64     `json:"size"`
+	Mode       string    `json:"mode"`
+	ModTime    time.Time `json:"mod_time"`
+	CapturedAt time.Time `json:"captured_at"`
+	LogID      string    `json:"log_id,omitempty"` // the log of the change that was captured
+}
+
+type store struct {
+	appLogger *slog.Logger
+	config    *config.Config
+	events    eventbus.Bus
+	paths     PathChecker
+	dir       string
+
+	mu       sync.Mutex
+	versions map[string][]Version // by path, oldest first
+	index    *os.File
+	refs     map[string]int // versions using each content, by sha256
+	total    int64          // size of the contents in use
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New opens the snapshot store in cfg.SnapshotsDir, or in the data
+// directory, and loads the versions kept so far
+func New(appLogger *slog.Logger, cfg *config.Config, events eventbus.Bus, paths PathChecker) (Store, error) {
+	dir := cfg.SnapshotsDir
+	if dir == "" {
+		dir = filepath.Join(cfg.DataDir, "snapshots")
+	}
+
+	for _, sub := range []string{"blobs", "tmp"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o755)
+		if err != nil {
+			return nil, fmt.Errorf("error creating snapshot directory: %w", err)
+		}
+	}
+
+	versions, err := loadIndex(dir)
+	if err != nil {
+		return nil, err
+	}
+	index, err := openIndex(dir, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{
+		appLogger: appLogger,
+		config:    cfg,
+		events:    events,
+		paths:     paths,
+		dir:       dir,
+		versions:  versions,
+		index:     index,
+		refs:      make(map[string]int),
+	}
+	for _, pathVersions := range versions {
+		for _, version := range pathVersions {
+			s.retain(version)
+		}
+	}
+
+	return s, nil
+}
+
+// Start copies the files of the changes published on the bus until Stop
+func (s *store) Start(ctx context.Context) error {
+	s.mu.Lock()
+	err := s.prune(time.Now())
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	go s.run(ctx)
+
+	return nil
+}
+
+func (s *store) Stop(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index.Close()
+}
+
+func (s *store) run(ctx context.Context) {
+	defer close(s.done)
+
+	match := func(event eventbus.Event) bool {
+		return event.Topic == eventbus.TopicFileChange
+	}
+	sub := s.events.Subscribe(match)
+	defer func() { s.events.Unsubscribe(sub) }()
+
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			err := s.prune(now)
+			s.mu.Unlock()
+			if err != nil {
+				s.appLogger.Error("error-pruning-snapshots", slog.String("error", err.Error()))
+			}
+		case event, ok := <-sub.Events():
+			if !ok {
+				// evicted, the changes missed are caught on their next change
+				s.appLogger.Warn("snapshot-subscription-evicted")
+				sub = s.events.Subscribe(match)
+				continue
+			}
+
+			entry, ok := event.Data.(mongolog.LogEntry)
+			if !ok || !captureActions[entry.Details["action"]] {
+				continue
+			}
+
+			_, _, err := s.capture(entry.Details["target_path"], entry.ID)
+			if err != nil {
+				s.appLogger.Error("error-capturing-snapshot", slog.String("target_path", entry.Details["target_path"]), slog.String("error", err.Error()))
+			}
+		}
+	}
+}
+
+// capture copies the file as a new version, ok is false when nothing was
+// copied: the file is gone, too large or unchanged since its last version
+func (s *store) capture(path, logID string) (version Version, ok bool, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Version{}, false, nil
+		}
+		return Version{}, false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return Version{}, false, err
+	}
+	maxSize := s.config.SnapshotMaxFileSize
+	if !info.Mode().IsRegular() || (maxSize > 0 && info.Size() > maxSize) {
+		return Version{}, false, nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Join(s.dir, "tmp"), "blob-*")
+	if err != nil {
+		return Version{}, false, fmt.Errorf("error creating snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	var src io.Reader = file
+	if maxSize > 0 {
+		// the file may have grown since it was checked
+		src = io.LimitReader(file, maxSize+1)
+	}
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	closeErr := tmp.Close()
+	if err != nil {
+		return Version{}, false, fmt.Errorf("error copying %s: %w", path, err)
+	}
+	if closeErr != nil {
+		return Version{}, false, fmt.Errorf("error creating snapshot: %w", closeErr)
+	}
+	if maxSize > 0 && size > maxSize {
+		return Version{}, false, nil
+	}
+	sha := hex.EncodeToString(hash.Sum(nil))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.versions[path]
+	if len(versions) > 0 && versions[len(versions)-1].SHA256 == sha {
+		return versions[len(versions)-1], false, nil
+	}
+
+	blob := s.blobPath(sha)
+	_, err = os.Stat(blob)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = os.MkdirAll(filepath.Dir(blob), 0o755)
+		if err == nil {
+			err = os.Rename(tmp.Name(), blob)
+		}
+	}
+	if err != nil {
+		return Version{}, false, fmt.Errorf("error storing snapshot: %w", err)
+	}
+
+	version = Version{
+		ID:         uuid.NewString(),
+		Path:       path,
+		SHA256:     sha,
+		Size:       size,
+		Mode:       fmt.Sprintf("%04o", info.Mode().Perm()),
+		ModTime:    info.ModTime().UTC(),
+		CapturedAt: time.Now().UTC(),
+		LogID:      logID,
+	}
+	err = s.add(version)
+	if err != nil {
+		return Version{}, false, err
+	}
+
+	return version, true, s.limit(path)
+}
+
+// Versions returns the versions kept of path, newest first
+func (s *store) Versions(path string) ([]Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	versions := s.versions[path]
+	res := make([]Version, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		res = append(res, versions[i])
+	}
+
+	return res, nil
+}
+
+func (s *store) version(path, id string) (Version, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, version := range s.versions[path] {
+		if version.ID == id {
+			return version, nil
+		}
+	}
+
+	return Version{}, ErrVersionNotFound
+}
+
+// blobPath spreads the contents over subdirectories by hash prefix
+func (s *store) blobPath(sha string) string {
+	return filepath.Join(s.dir, "blobs", sha[:2], sha)
+}
@@ -0,0 +1,277 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/danielboakye/filechangestracker/internal/config"
+	"github.com/danielboakye/filechangestracker/internal/eventbus"
+	"github.com/danielboakye/filechangestracker/internal/mongolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover ./internal/snapshot/...
+
+var errOutside = errors.New("outside the tracked directories")
+
+// pathsWithin allows restores below root only
+type pathsWithin string
+
+func (p pathsWithin) CheckPath(path string) error {
+	if !strings.HasPrefix(path, string(p)+string(filepath.Separator)) {
+		return errOutside
+	}
+	return nil
+}
+
+func newTestStore(t *testing.T, cfg *config.Config, root string) *store {
+	cfg.DataDir = t.TempDir()
+	s, err := New(slog.Default(), cfg, eventbus.New(slog.Default()), pathsWithin(root))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Stop(context.Background()) })
+
+	return s.(*store)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+// go test -v -cover -run TestCapture ./internal/snapshot
+func TestCapture(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	s := newTestStore(t, &config.Config{SnapshotMaxFileSize: 20}, dir)
+
+	writeFile(t, path, "one\n")
+	first, ok, err := s.capture(path, "log-1")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "log-1", first.LogID)
+	assert.Equal(t, "0644", first.Mode)
+
+	// unchanged content is not kept twice
+	_, ok, err = s.capture(path, "log-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	writeFile(t, path, "two\n")
+	second, ok, err := s.capture(path, "log-3")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	writeFile(t, path, strings.Repeat("too large", 10))
+	_, ok, err = s.capture(path, "log-4")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = s.capture(filepath.Join(dir, "gone.txt"), "log-5")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	versions, err := s.Versions(path)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, second.ID, versions[0].ID)
+	assert.Equal(t, first.ID, versions[1].ID)
+
+	// the index survives a restart
+	reopened, err := New(slog.Default(), s.config, eventbus.New(slog.Default()), pathsWithin(dir))
+	require.NoError(t, err)
+	versions, err = reopened.Versions(path)
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+}
+
+// go test -v -cover -run TestCapture_FromEvents ./internal/snapshot
+func TestCapture_FromEvents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	writeFile(t, path, "one\n")
+
+	cfg := &config.Config{DataDir: t.TempDir()}
+	events := eventbus.New(slog.Default())
+	s, err := New(slog.Default(), cfg, events, pathsWithin(dir))
+	require.NoError(t, err)
+	require.NoError(t, s.Start(context.Background()))
+	defer s.Stop(context.Background())
+
+	require.Eventually(t, func() bool {
+		// the subscription starts in the background
+		events.Publish(eventbus.Event{
+			Topic: eventbus.TopicFileChange,
+			Data:  mongolog.LogEntry{ID: "log-1", Details: map[string]string{"target_path": path, "action": "CREATED"}},
+		})
+		versions, err := s.Versions(path)
+		return err == nil && len(versions) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// go test -v -cover -run TestPrune ./internal/snapshot
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	b := filepath.Join(dir, "b.txt")
+	s := newTestStore(t, &config.Config{SnapshotMaxVersions: 2, SnapshotMaxTotalSize: 10}, dir)
+
+	var captured []Version
+	for _, content := range []string{"a-1\n", "a-2\n", "a-3\n"} {
+		writeFile(t, a, content)
+		version, ok, err := s.capture(a, "")
+		require.NoError(t, err)
+		require.True(t, ok)
+		captured = append(captured, version)
+	}
+
+	// only the last two versions are kept, the content of the first goes
+	versions, err := s.Versions(a)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, captured[2].ID, versions[0].ID)
+	assert.NoFileExists(t, s.blobPath(captured[0].SHA256))
+	assert.FileExists(t, s.blobPath(captured[1].SHA256))
+
+	// 4 more bytes go over the 10 byte total, the oldest version across
+	// files goes
+	writeFile(t, b, "b-1\n")
+	_, ok, err := s.capture(b, "")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	versions, err = s.Versions(a)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, captured[2].ID, versions[0].ID)
+	assert.NoFileExists(t, s.blobPath(captured[1].SHA256))
+
+	// the newest version of a file is kept past max_age
+	s.config.SnapshotMaxAge = 1
+	s.mu.Lock()
+	require.NoError(t, s.prune(time.Now().Add(time.Hour)))
+	s.mu.Unlock()
+	versions, err = s.Versions(a)
+	require.NoError(t, err)
+	assert.Len(t, versions, 1)
+}
+
+// go test -v -cover -run TestDiff ./internal/snapshot
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+	s := newTestStore(t, &config.Config{}, dir)
+
+	writeFile(t, path, "one\ntwo\nthree\n")
+	first, _, err := s.capture(path, "")
+	require.NoError(t, err)
+	writeFile(t, path, "one\n2\nthree\n")
+	second, _, err := s.capture(path, "")
+	require.NoError(t, err)
+
+	diff, err := s.Diff(path, first.ID, second.ID)
+	require.NoError(t, err)
+	assert.Contains(t, diff, "--- "+path+"@"+first.ID)
+	assert.Contains(t, diff, "+++ "+path+"@"+second.ID)
+	assert.Contains(t, diff, "-two\n+2\n")
+
+	writeFile(t, path, "one\n2\nthree\nfour\n")
+	diff, err = s.Diff(path, second.ID, "")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+four\n")
+
+	_, err = s.Diff(path, "missing", "")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	binary := filepath.Join(dir, "image.bin")
+	writeFile(t, binary, "\x89PNG\x00\x01")
+	version, _, err := s.capture(binary, "")
+	require.NoError(t, err)
+	_, err = s.Diff(binary, version.ID, "")
+	assert.ErrorIs(t, err, ErrNotText)
+}
+
+// go test -v -cover -run TestRestore ./internal/snapshot
+func TestRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sub", "notes.txt")
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	s := newTestStore(t, &config.Config{}, dir)
+
+	writeFile(t, path, "original\n")
+	require.NoError(t, os.Chmod(path, 0o600))
+	version, _, err := s.capture(path, "")
+	require.NoError(t, err)
+
+	// deleted along with its directory
+	require.NoError(t, os.RemoveAll(filepath.Dir(path)))
+
+	restored, err := s.Restore(path, version.ID)
+	require.NoError(t, err)
+	assert.Equal(t, version.ID, restored.ID)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "original\n", string(content))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	_, err = s.Restore(path, "missing")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+
+	// the path checks apply even to a version of that path
+	s.paths = pathsWithin(filepath.Join(dir, "elsewhere"))
+	_, err = s.Restore(path, version.ID)
+	assert.ErrorIs(t, err, errOutside)
+}
+
+// go test -v -cover -run TestIndex ./internal/snapshot
+func TestIndex(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.txt")
+	s := newTestStore(t, &config.Config{SnapshotMaxVersions: 2}, dir)
+
+	var captured []Version
+	for _, content := range []string{"a-1\n", "a-2\n", "a-3\n"} {
+		writeFile(t, a, content)
+		version, ok, err := s.capture(a, "")
+		require.NoError(t, err)
+		require.True(t, ok)
+		captured = append(captured, version)
+	}
+
+	// each capture and prune appends a line instead of rewriting the index
+	data, err := os.ReadFile(filepath.Join(s.dir, indexFileName))
+	require.NoError(t, err)
+	assert.Equal(t, 4, strings.Count(string(data), "\n"))
+	assert.Equal(t, int64(8), s.total)
+
+	// a line cut short by a crash
+	file, err := os.OpenFile(filepath.Join(s.dir, indexFileName), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"add":{"id":"cut-sh`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	require.NoError(t, s.Stop(context.Background()))
+
+	reopened, err := New(slog.Default(), s.config, eventbus.New(slog.Default()), pathsWithin(dir))
+	require.NoError(t, err)
+	defer reopened.Stop(context.Background())
+	versions, err := reopened.Versions(a)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	assert.Equal(t, captured[2].ID, versions[0].ID)
+	assert.Equal(t, captured[1].ID, versions[1].ID)
+	assert.Equal(t, int64(8), reopened.(*store).total)
+
+	// reopening compacts the index to one line per version
+	data, err = os.ReadFile(filepath.Join(s.dir, indexFileName))
+	require.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+}
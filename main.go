@@ -22,6 +22,7 @@ func main() {
 		HideWindowOnClose: true,
 		LogLevel:          logger.DEBUG,
 		Assets:            assets,
+		OnStartup:         app.OnStartup,
 		Bind: []interface{}{
 			app,
 		},
@@ -8,6 +8,7 @@ import (
 	context "context"
 	reflect "reflect"
 
+	commandexecutor "github.com/danielboakye/filechangestracker/internal/commandexecutor"
 	gomock "github.com/golang/mock/gomock"
 )
 
@@ -35,17 +36,62 @@ func (m *MockCommandExecutor) EXPECT() *MockCommandExecutorMockRecorder {
 }
 
 // AddCommands mocks base method.
-func (m *MockCommandExecutor) AddCommands(commands []string) error {
+func (m *MockCommandExecutor) AddCommands(orderingKey string, commands []string) ([]commandexecutor.Job, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddCommands", commands)
+	ret := m.ctrl.Call(m, "AddCommands", orderingKey, commands)
+	ret0, _ := ret[0].([]commandexecutor.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddCommands indicates an expected call of AddCommands.
+func (mr *MockCommandExecutorMockRecorder) AddCommands(orderingKey, commands interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCommands", reflect.TypeOf((*MockCommandExecutor)(nil).AddCommands), orderingKey, commands)
+}
+
+// CancelJob mocks base method.
+func (m *MockCommandExecutor) CancelJob(id string) (commandexecutor.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CancelJob", id)
+	ret0, _ := ret[0].(commandexecutor.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CancelJob indicates an expected call of CancelJob.
+func (mr *MockCommandExecutorMockRecorder) CancelJob(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CancelJob", reflect.TypeOf((*MockCommandExecutor)(nil).CancelJob), id)
+}
+
+// CheckPath mocks base method.
+func (m *MockCommandExecutor) CheckPath(path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckPath", path)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// AddCommands indicates an expected call of AddCommands.
-func (mr *MockCommandExecutorMockRecorder) AddCommands(commands interface{}) *gomock.Call {
+// CheckPath indicates an expected call of CheckPath.
+func (mr *MockCommandExecutorMockRecorder) CheckPath(path interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddCommands", reflect.TypeOf((*MockCommandExecutor)(nil).AddCommands), commands)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPath", reflect.TypeOf((*MockCommandExecutor)(nil).CheckPath), path)
+}
+
+// GetJob mocks base method.
+func (m *MockCommandExecutor) GetJob(id string) (commandexecutor.Job, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetJob", id)
+	ret0, _ := ret[0].(commandexecutor.Job)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetJob indicates an expected call of GetJob.
+func (mr *MockCommandExecutorMockRecorder) GetJob(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetJob", reflect.TypeOf((*MockCommandExecutor)(nil).GetJob), id)
 }
 
 // IsWorkerThreadAlive mocks base method.
@@ -62,6 +108,20 @@ func (mr *MockCommandExecutorMockRecorder) IsWorkerThreadAlive() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsWorkerThreadAlive", reflect.TypeOf((*MockCommandExecutor)(nil).IsWorkerThreadAlive))
 }
 
+// QueueStatus mocks base method.
+func (m *MockCommandExecutor) QueueStatus() commandexecutor.QueueStatus {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "QueueStatus")
+	ret0, _ := ret[0].(commandexecutor.QueueStatus)
+	return ret0
+}
+
+// QueueStatus indicates an expected call of QueueStatus.
+func (mr *MockCommandExecutorMockRecorder) QueueStatus() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "QueueStatus", reflect.TypeOf((*MockCommandExecutor)(nil).QueueStatus))
+}
+
 // Start mocks base method.
 func (m *MockCommandExecutor) Start(ctx context.Context) error {
 	m.ctrl.T.Helper()
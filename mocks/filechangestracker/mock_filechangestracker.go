@@ -8,6 +8,8 @@ import (
 	context "context"
 	reflect "reflect"
 
+	config "github.com/danielboakye/filechangestracker/internal/config"
+	filechangestracker "github.com/danielboakye/filechangestracker/internal/filechangestracker"
 	mongolog "github.com/danielboakye/filechangestracker/internal/mongolog"
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,19 +37,64 @@ func (m *MockFileChangesTracker) EXPECT() *MockFileChangesTrackerMockRecorder {
 	return m.recorder
 }
 
+// AddWatch mocks base method.
+func (m *MockFileChangesTracker) AddWatch(w config.WatchConfig) (config.WatchConfig, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWatch", w)
+	ret0, _ := ret[0].(config.WatchConfig)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWatch indicates an expected call of AddWatch.
+func (mr *MockFileChangesTrackerMockRecorder) AddWatch(w interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWatch", reflect.TypeOf((*MockFileChangesTracker)(nil).AddWatch), w)
+}
+
 // GetLogs mocks base method.
-func (m *MockFileChangesTracker) GetLogs(ctx context.Context, limit, offset int64) ([]mongolog.LogEntry, error) {
+func (m *MockFileChangesTracker) GetLogs(ctx context.Context, query mongolog.LogQuery) (mongolog.LogPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetLogs", ctx, limit, offset)
-	ret0, _ := ret[0].([]mongolog.LogEntry)
+	ret := m.ctrl.Call(m, "GetLogs", ctx, query)
+	ret0, _ := ret[0].(mongolog.LogPage)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetLogs indicates an expected call of GetLogs.
-func (mr *MockFileChangesTrackerMockRecorder) GetLogs(ctx, limit, offset interface{}) *gomock.Call {
+func (mr *MockFileChangesTrackerMockRecorder) GetLogs(ctx, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockFileChangesTracker)(nil).GetLogs), ctx, limit, offset)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogs", reflect.TypeOf((*MockFileChangesTracker)(nil).GetLogs), ctx, query)
+}
+
+// GetLogsAfter mocks base method.
+func (m *MockFileChangesTracker) GetLogsAfter(ctx context.Context, query mongolog.LogQuery) ([]mongolog.LogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetLogsAfter", ctx, query)
+	ret0, _ := ret[0].([]mongolog.LogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetLogsAfter indicates an expected call of GetLogsAfter.
+func (mr *MockFileChangesTrackerMockRecorder) GetLogsAfter(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLogsAfter", reflect.TypeOf((*MockFileChangesTracker)(nil).GetLogsAfter), ctx, query)
+}
+
+// GetScan mocks base method.
+func (m *MockFileChangesTracker) GetScan(id string) (filechangestracker.ScanStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScan", id)
+	ret0, _ := ret[0].(filechangestracker.ScanStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScan indicates an expected call of GetScan.
+func (mr *MockFileChangesTrackerMockRecorder) GetScan(id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScan", reflect.TypeOf((*MockFileChangesTracker)(nil).GetScan), id)
 }
 
 // IsTimerThreadAlive mocks base method.
@@ -64,6 +111,34 @@ func (mr *MockFileChangesTrackerMockRecorder) IsTimerThreadAlive() *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTimerThreadAlive", reflect.TypeOf((*MockFileChangesTracker)(nil).IsTimerThreadAlive))
 }
 
+// ListWatches mocks base method.
+func (m *MockFileChangesTracker) ListWatches() []config.WatchConfig {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWatches")
+	ret0, _ := ret[0].([]config.WatchConfig)
+	return ret0
+}
+
+// ListWatches indicates an expected call of ListWatches.
+func (mr *MockFileChangesTrackerMockRecorder) ListWatches() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWatches", reflect.TypeOf((*MockFileChangesTracker)(nil).ListWatches))
+}
+
+// RemoveWatch mocks base method.
+func (m *MockFileChangesTracker) RemoveWatch(path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWatch", path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWatch indicates an expected call of RemoveWatch.
+func (mr *MockFileChangesTrackerMockRecorder) RemoveWatch(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWatch", reflect.TypeOf((*MockFileChangesTracker)(nil).RemoveWatch), path)
+}
+
 // Start mocks base method.
 func (m *MockFileChangesTracker) Start(ctx context.Context) error {
 	m.ctrl.T.Helper()
@@ -78,6 +153,21 @@ func (mr *MockFileChangesTrackerMockRecorder) Start(ctx interface{}) *gomock.Cal
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockFileChangesTracker)(nil).Start), ctx)
 }
 
+// StartScan mocks base method.
+func (m *MockFileChangesTracker) StartScan() (filechangestracker.ScanStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "StartScan")
+	ret0, _ := ret[0].(filechangestracker.ScanStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StartScan indicates an expected call of StartScan.
+func (mr *MockFileChangesTrackerMockRecorder) StartScan() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StartScan", reflect.TypeOf((*MockFileChangesTracker)(nil).StartScan))
+}
+
 // Stop mocks base method.
 func (m *MockFileChangesTracker) Stop(ctx context.Context) error {
 	m.ctrl.T.Helper()
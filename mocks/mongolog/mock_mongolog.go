@@ -49,31 +49,92 @@ func (mr *MockLogStoreMockRecorder) Close(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockLogStore)(nil).Close), ctx)
 }
 
-// ReadLogsPaginated mocks base method.
-func (m *MockLogStore) ReadLogsPaginated(ctx context.Context, page, pageSize int64) ([]mongolog.LogEntry, error) {
+// LoadBaseline mocks base method.
+func (m *MockLogStore) LoadBaseline(ctx context.Context) (mongolog.Baseline, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadBaseline", ctx)
+	ret0, _ := ret[0].(mongolog.Baseline)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// LoadBaseline indicates an expected call of LoadBaseline.
+func (mr *MockLogStoreMockRecorder) LoadBaseline(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadBaseline", reflect.TypeOf((*MockLogStore)(nil).LoadBaseline), ctx)
+}
+
+// ReadLogsAfter mocks base method.
+func (m *MockLogStore) ReadLogsAfter(ctx context.Context, query mongolog.LogQuery) ([]mongolog.LogEntry, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ReadLogsPaginated", ctx, page, pageSize)
+	ret := m.ctrl.Call(m, "ReadLogsAfter", ctx, query)
 	ret0, _ := ret[0].([]mongolog.LogEntry)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
+// ReadLogsAfter indicates an expected call of ReadLogsAfter.
+func (mr *MockLogStoreMockRecorder) ReadLogsAfter(ctx, query interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLogsAfter", reflect.TypeOf((*MockLogStore)(nil).ReadLogsAfter), ctx, query)
+}
+
+// ReadLogsPaginated mocks base method.
+func (m *MockLogStore) ReadLogsPaginated(ctx context.Context, query mongolog.LogQuery) (mongolog.LogPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadLogsPaginated", ctx, query)
+	ret0, _ := ret[0].(mongolog.LogPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // ReadLogsPaginated indicates an expected call of ReadLogsPaginated.
-func (mr *MockLogStoreMockRecorder) ReadLogsPaginated(ctx, page, pageSize interface{}) *gomock.Call {
+func (mr *MockLogStoreMockRecorder) ReadLogsPaginated(ctx, query interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLogsPaginated", reflect.TypeOf((*MockLogStore)(nil).ReadLogsPaginated), ctx, page, pageSize)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadLogsPaginated", reflect.TypeOf((*MockLogStore)(nil).ReadLogsPaginated), ctx, query)
 }
 
-// Write mocks base method.
-func (m *MockLogStore) Write(ctx context.Context, logDetail map[string]string) error {
+// UpdateBaseline mocks base method.
+func (m *MockLogStore) UpdateBaseline(ctx context.Context, update mongolog.BaselineUpdate) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Write", ctx, logDetail)
+	ret := m.ctrl.Call(m, "UpdateBaseline", ctx, update)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// UpdateBaseline indicates an expected call of UpdateBaseline.
+func (mr *MockLogStoreMockRecorder) UpdateBaseline(ctx, update interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateBaseline", reflect.TypeOf((*MockLogStore)(nil).UpdateBaseline), ctx, update)
+}
+
+// Write mocks base method.
+func (m *MockLogStore) Write(ctx context.Context, logDetail map[string]string) (mongolog.LogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Write", ctx, logDetail)
+	ret0, _ := ret[0].(mongolog.LogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Write indicates an expected call of Write.
 func (mr *MockLogStoreMockRecorder) Write(ctx, logDetail interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Write", reflect.TypeOf((*MockLogStore)(nil).Write), ctx, logDetail)
 }
+
+// WriteBatch mocks base method.
+func (m *MockLogStore) WriteBatch(ctx context.Context, logDetails []map[string]string) ([]mongolog.LogEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WriteBatch", ctx, logDetails)
+	ret0, _ := ret[0].([]mongolog.LogEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// WriteBatch indicates an expected call of WriteBatch.
+func (mr *MockLogStoreMockRecorder) WriteBatch(ctx, logDetails interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WriteBatch", reflect.TypeOf((*MockLogStore)(nil).WriteBatch), ctx, logDetails)
+}
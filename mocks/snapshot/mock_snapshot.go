@@ -0,0 +1,146 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: snapshot.go
+
+// Package snapshotmock is a generated GoMock package.
+package snapshotmock
+
+import (
+	context "context"
+	reflect "reflect"
+
+	snapshot "github.com/danielboakye/filechangestracker/internal/snapshot"
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockStore is a mock of Store interface.
+type MockStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockStoreMockRecorder
+}
+
+// MockStoreMockRecorder is the mock recorder for MockStore.
+type MockStoreMockRecorder struct {
+	mock *MockStore
+}
+
+// NewMockStore creates a new mock instance.
+func NewMockStore(ctrl *gomock.Controller) *MockStore {
+	mock := &MockStore{ctrl: ctrl}
+	mock.recorder = &MockStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockStore) EXPECT() *MockStoreMockRecorder {
+	return m.recorder
+}
+
+// Diff mocks base method.
+func (m *MockStore) Diff(path, from, to string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Diff", path, from, to)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Diff indicates an expected call of Diff.
+func (mr *MockStoreMockRecorder) Diff(path, from, to interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Diff", reflect.TypeOf((*MockStore)(nil).Diff), path, from, to)
+}
+
+// Restore mocks base method.
+func (m *MockStore) Restore(path, id string) (snapshot.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", path, id)
+	ret0, _ := ret[0].(snapshot.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockStoreMockRecorder) Restore(path, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockStore)(nil).Restore), path, id)
+}
+
+// Start mocks base method.
+func (m *MockStore) Start(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Start", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Start indicates an expected call of Start.
+func (mr *MockStoreMockRecorder) Start(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Start", reflect.TypeOf((*MockStore)(nil).Start), ctx)
+}
+
+// Stop mocks base method.
+func (m *MockStore) Stop(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stop", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Stop indicates an expected call of Stop.
+func (mr *MockStoreMockRecorder) Stop(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stop", reflect.TypeOf((*MockStore)(nil).Stop), ctx)
+}
+
+// Versions mocks base method.
+func (m *MockStore) Versions(path string) ([]snapshot.Version, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Versions", path)
+	ret0, _ := ret[0].([]snapshot.Version)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Versions indicates an expected call of Versions.
+func (mr *MockStoreMockRecorder) Versions(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Versions", reflect.TypeOf((*MockStore)(nil).Versions), path)
+}
+
+// MockPathChecker is a mock of PathChecker interface.
+type MockPathChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockPathCheckerMockRecorder
+}
+
+// MockPathCheckerMockRecorder is the mock recorder for MockPathChecker.
+type MockPathCheckerMockRecorder struct {
+	mock *MockPathChecker
+}
+
+// NewMockPathChecker creates a new mock instance.
+func NewMockPathChecker(ctrl *gomock.Controller) *MockPathChecker {
+	mock := &MockPathChecker{ctrl: ctrl}
+	mock.recorder = &MockPathCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPathChecker) EXPECT() *MockPathCheckerMockRecorder {
+	return m.recorder
+}
+
+// CheckPath mocks base method.
+func (m *MockPathChecker) CheckPath(path string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckPath", path)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckPath indicates an expected call of CheckPath.
+func (mr *MockPathCheckerMockRecorder) CheckPath(path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckPath", reflect.TypeOf((*MockPathChecker)(nil).CheckPath), path)
+}
@@ -0,0 +1,37 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover ./pkg/atomicfile/...
+
+// go test -v -cover -run TestWriteFile ./pkg/atomicfile
+func TestWriteFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state", "checkpoint.json")
+
+	require.NoError(WriteFile(path, []byte("one"), 0o600))
+	require.NoError(WriteFile(path, []byte("two"), 0o600))
+
+	data, err := os.ReadFile(path)
+	require.NoError(err)
+	assert.Equal("two", string(data))
+
+	info, err := os.Stat(path)
+	require.NoError(err)
+	assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+
+	// no temp files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(err)
+	assert.Len(entries, 1)
+}
@@ -0,0 +1,202 @@
+// Package fuzzyhash computes ssdeep context triggered piecewise hashes.
+// Files that differ by a few edits get digests that share most of their
+// characters, unlike cryptographic hashes.
+package fuzzyhash
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	minBlockSize  = 3
+	numBlockSizes = 31
+	spamSumLength = 64 // characters of the first signature
+	rollingWindow = 7
+	hashInit      = 0x28021967
+	hashPrime     = 0x01000193
+
+	b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// rollingHash is the hash of the last rollingWindow bytes, the block
+// boundaries are where it hits the block size
+type rollingHash struct {
+	window     [rollingWindow]byte
+	h1, h2, h3 uint32
+	n          uint32
+}
+
+func (r *rollingHash) roll(c byte) {
+	r.h2 -= r.h1
+	r.h2 += rollingWindow * uint32(c)
+
+	r.h1 += uint32(c)
+	r.h1 -= uint32(r.window[r.n%rollingWindow])
+
+	r.window[r.n%rollingWindow] = c
+	r.n++
+
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+}
+
+func (r *rollingHash) sum() uint32 {
+	return r.h1 + r.h2 + r.h3
+}
+
+// blockHash is the signature for one block size, halfDigest is the last
+// character it has once the signature is half full, for when it ends up
+// as the second signature
+type blockHash struct {
+	h, halfH   uint32
+	digest     []byte
+	halfDigest byte
+}
+
+// Hasher computes the digest of the bytes written to it. Every block size
+// that can still be picked is hashed in the same pass, so the input never
+// has to be read twice.
+type Hasher struct {
+	roll   rollingHash
+	blocks [numBlockSizes]blockHash
+	start  int // block sizes below start were too small for the input
+	end    int // block sizes from end on have not been triggered yet
+	size   uint64
+}
+
+func New() *Hasher {
+	h := &Hasher{end: 1}
+	h.blocks[0] = blockHash{h: hashInit, halfH: hashInit}
+	return h
+}
+
+func blockSize(i int) uint64 {
+	return minBlockSize << i
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	for _, c := range p {
+		h.size++
+		h.step(c)
+	}
+	return len(p), nil
+}
+
+func (h *Hasher) step(c byte) {
+	h.roll.roll(c)
+	sum := uint64(h.roll.sum())
+
+	for i := h.start; i < h.end; i++ {
+		h.blocks[i].h = (h.blocks[i].h * hashPrime) ^ uint32(c)
+		h.blocks[i].halfH = (h.blocks[i].halfH * hashPrime) ^ uint32(c)
+	}
+
+	for i := h.start; i < h.end; i++ {
+		// block sizes double, a boundary of a larger one is one of each
+		// smaller one too
+		if sum%blockSize(i) != blockSize(i)-1 {
+			break
+		}
+
+		b := &h.blocks[i]
+		if len(b.digest) == 0 {
+			h.fork()
+		}
+
+		if len(b.digest) < spamSumLength-1 {
+			b.digest = append(b.digest, b64[b.h%64])
+			b.halfDigest = b64[b.halfH%64]
+			b.h = hashInit
+			if len(b.digest) < spamSumLength/2 {
+				b.halfH = hashInit
+				b.halfDigest = 0
+			}
+		} else {
+			// the last character keeps hashing the rest of the input
+			b.digest = append(b.digest[:spamSumLength-1], b64[b.h%64])
+			b.halfDigest = b64[b.halfH%64]
+			h.reduce()
+		}
+	}
+}
+
+// fork starts hashing the next block size, from the state of the current
+// largest one
+func (h *Hasher) fork() {
+	if h.end >= numBlockSizes {
+		return
+	}
+
+	last := h.blocks[h.end-1]
+	h.blocks[h.end] = blockHash{h: last.h, halfH: last.halfH}
+	h.end++
+}
+
+// reduce stops hashing the smallest block size once the next one has a
+// long enough signature for the input read so far
+func (h *Hasher) reduce() {
+	if h.end-h.start < 2 {
+		return
+	}
+	if blockSize(h.start)*spamSumLength >= h.size {
+		return
+	}
+	if len(h.blocks[h.start+1].digest) < spamSumLength/2 {
+		return
+	}
+
+	h.start++
+}
+
+// Sum returns the digest as blocksize:signature:signature
+func (h *Hasher) Sum() string {
+	i := h.start
+	for blockSize(i)*spamSumLength < h.size {
+		i++
+	}
+	if i >= h.end {
+		i = h.end - 1
+	}
+	for i > h.start && len(h.blocks[i].digest) < spamSumLength/2 {
+		i--
+	}
+
+	rolling := h.roll.sum()
+
+	var b strings.Builder
+	b.WriteString(strconv.FormatUint(blockSize(i), 10))
+	b.WriteByte(':')
+
+	first := h.blocks[i]
+	digest := first.digest
+	if len(digest) == spamSumLength {
+		digest = digest[:spamSumLength-1]
+		if rolling == 0 {
+			digest = first.digest
+		}
+	}
+	b.Write(digest)
+	if rolling != 0 {
+		b.WriteByte(b64[first.h%64])
+	}
+	b.WriteByte(':')
+
+	if i < h.end-1 {
+		second := h.blocks[i+1]
+		digest := second.digest
+		if len(digest) > spamSumLength/2-1 {
+			digest = digest[:spamSumLength/2-1]
+		}
+		b.Write(digest)
+		if rolling != 0 {
+			b.WriteByte(b64[second.halfH%64])
+		} else if second.halfDigest != 0 {
+			b.WriteByte(second.halfDigest)
+		}
+	} else if rolling != 0 {
+		b.WriteByte(b64[first.h%64])
+	}
+
+	return b.String()
+}
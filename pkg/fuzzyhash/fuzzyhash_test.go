@@ -0,0 +1,74 @@
+package fuzzyhash
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// go test -v -cover ./pkg/fuzzyhash/...
+
+func hash(data []byte) string {
+	h := New()
+	h.Write(data)
+	return h.Sum()
+}
+
+// go test -v -cover -run TestHash ./pkg/fuzzyhash
+func TestHash(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	assert.Equal("3::", hash(nil))
+
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	digest := hash(data)
+	parts := strings.Split(digest, ":")
+	require.Len(parts, 3)
+	assert.Equal("1536", parts[0]) // smallest 3*2^n with 64 blocks covering the data
+	assert.GreaterOrEqual(len(parts[1]), spamSumLength/2)
+	assert.LessOrEqual(len(parts[1]), spamSumLength)
+	assert.LessOrEqual(len(parts[2]), spamSumLength/2)
+
+	// the digest doesn't depend on how the data is written
+	h := New()
+	for i := 0; i < len(data); i += 1000 {
+		h.Write(data[i:min(i+1000, len(data))])
+	}
+	assert.Equal(digest, h.Sum())
+}
+
+// go test -v -cover -run TestHash_SimilarInputs ./pkg/fuzzyhash
+func TestHash_SimilarInputs(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data := make([]byte, 64*1024)
+	rand.New(rand.NewSource(2)).Read(data)
+	edited := append([]byte{}, data...)
+	copy(edited[30000:], "a small edit in the middle of the file")
+
+	original := strings.Split(hash(data), ":")
+	changed := strings.Split(hash(edited), ":")
+	require.Len(changed, 3)
+	assert.Equal(original[0], changed[0])
+	assert.NotEqual(original[1], changed[1])
+
+	// blocks before and after the edit are unchanged
+	same := 0
+	for i := 0; i < len(original[1]) && i < len(changed[1]); i++ {
+		if original[1][i] == changed[1][i] {
+			same++
+		}
+	}
+	assert.Greater(same, len(original[1])/2)
+
+	other := make([]byte, 64*1024)
+	rand.New(rand.NewSource(3)).Read(other)
+	assert.NotEqual(original[1], strings.Split(hash(other), ":")[1])
+}
@@ -0,0 +1,133 @@
+package osquerymanager
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// likeEscape is the escape character declared on every LIKE the builder emits
+const likeEscape = `\`
+
+var identifierPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// QueryBuilder builds SELECT statements for osquery. Table and column names
+// are checked against a strict identifier pattern and every value is escaped,
+// so values coming from config or api requests can be used as is.
+type QueryBuilder struct {
+	table      string
+	columns    []string
+	conditions []string
+	err        error
+}
+
+// Select starts a query on table, selecting all columns when none are given
+func Select(table string, columns ...string) *QueryBuilder {
+	q := &QueryBuilder{table: table, columns: columns}
+	q.checkIdentifier(table)
+	for _, column := range columns {
+		q.checkIdentifier(column)
+	}
+
+	return q
+}
+
+// WhereLikePrefix matches rows where column starts with prefix. % and _ in
+// prefix are matched literally.
+func (q *QueryBuilder) WhereLikePrefix(column, prefix string) *QueryBuilder {
+	q.checkIdentifier(column)
+	q.checkLiteral(prefix)
+	q.conditions = append(q.conditions, fmt.Sprintf("%s LIKE %s ESCAPE %s", column, QuoteLiteral(EscapeLike(prefix)+"%"), QuoteLiteral(likeEscape)))
+
+	return q
+}
+
+// WhereIn matches rows where column equals one of values
+func (q *QueryBuilder) WhereIn(column string, values ...string) *QueryBuilder {
+	q.checkIdentifier(column)
+	if len(values) == 0 {
+		q.setErr(fmt.Errorf("no values for IN condition on column: %s", column))
+		return q
+	}
+
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		q.checkLiteral(value)
+		quoted = append(quoted, QuoteLiteral(value))
+	}
+	q.conditions = append(q.conditions, fmt.Sprintf("%s IN (%s)", column, strings.Join(quoted, ", ")))
+
+	return q
+}
+
+// WhereGreaterThanOrEqual matches rows where column is greater than or equal to value
+func (q *QueryBuilder) WhereGreaterThanOrEqual(column string, value int64) *QueryBuilder {
+	q.checkIdentifier(column)
+	q.conditions = append(q.conditions, fmt.Sprintf("%s >= %s", column, strconv.FormatInt(value, 10)))
+
+	return q
+}
+
+// Build returns the SQL statement or the first error hit while building it
+func (q *QueryBuilder) Build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+
+	columns := "*"
+	if len(q.columns) > 0 {
+		columns = strings.Join(q.columns, ", ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(columns)
+	sb.WriteString(" FROM ")
+	sb.WriteString(q.table)
+	if len(q.conditions) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.conditions, " AND "))
+	}
+	sb.WriteString(";")
+
+	return sb.String(), nil
+}
+
+func (q *QueryBuilder) checkIdentifier(name string) {
+	if !identifierPattern.MatchString(name) {
+		q.setErr(fmt.Errorf("invalid identifier: %q", name))
+	}
+}
+
+func (q *QueryBuilder) checkLiteral(value string) {
+	if strings.ContainsRune(value, 0) {
+		q.setErr(fmt.Errorf("invalid value: contains NUL byte"))
+	}
+}
+
+func (q *QueryBuilder) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// QuoteLiteral returns s as a SQL string literal, doubling single quotes
+func QuoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// EscapeLike escapes the LIKE wildcards % and _ (and the escape character
+// itself) so s is matched literally by a LIKE ... ESCAPE '\' clause.
+func EscapeLike(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '%', '_', '\\':
+			sb.WriteString(likeEscape)
+		}
+		sb.WriteRune(r)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,114 @@
+package osquerymanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// go test -v -cover ./pkg/osquerymanager/...
+
+// go test -v -cover -run TestQuoteLiteral ./pkg/osquerymanager
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain path", "/tmp/downloads", `'/tmp/downloads'`},
+		{"Single quote", "/tmp/it's", `'/tmp/it''s'`},
+		{"Closing quote injection", "/tmp/' OR 1=1 --", `'/tmp/'' OR 1=1 --'`},
+		{"Statement injection", "/tmp/'; DROP TABLE file_events; --", `'/tmp/''; DROP TABLE file_events; --'`},
+		{"Double quotes untouched", `/tmp/"quoted"`, `'/tmp/"quoted"'`},
+		{"Unicode", "/Users/zoë/Téléchargements", `'/Users/zoë/Téléchargements'`},
+		{"Empty", "", `''`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, QuoteLiteral(tt.input))
+		})
+	}
+}
+
+// go test -v -cover -run TestEscapeLike ./pkg/osquerymanager
+func TestEscapeLike(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Plain path", "/tmp/downloads/", "/tmp/downloads/"},
+		{"Percent", "/tmp/100%/", `/tmp/100\%/`},
+		{"Underscore", "/tmp/my_files/", `/tmp/my\_files/`},
+		{"Backslash", `/tmp/back\slash/`, `/tmp/back\\slash/`},
+		{"Wildcards only", "%_%", `\%\_\%`},
+		{"Spaces and dots", "/Users/me/My Files/v1.2/", "/Users/me/My Files/v1.2/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, EscapeLike(tt.input))
+		})
+	}
+}
+
+// go test -v -cover -run TestQueryBuilder ./pkg/osquerymanager
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    *QueryBuilder
+		expected string
+	}{
+		{
+			"Select all",
+			Select("file_events"),
+			"SELECT * FROM file_events;",
+		},
+		{
+			"Prefix and time",
+			Select("file_events").WhereLikePrefix("target_path", "/tmp/dl/").WhereGreaterThanOrEqual("time", 1700000000),
+			`SELECT * FROM file_events WHERE target_path LIKE '/tmp/dl/%' ESCAPE '\' AND time >= 1700000000;`,
+		},
+		{
+			"Hostile prefix",
+			Select("file_events").WhereLikePrefix("target_path", "/tmp/it's_100%/' OR '1'='1"),
+			`SELECT * FROM file_events WHERE target_path LIKE '/tmp/it''s\_100\%/'' OR ''1''=''1%' ESCAPE '\';`,
+		},
+		{
+			"Columns and IN",
+			Select("file_events", "target_path", "action").WhereIn("action", "CREATED", "DELETED'--"),
+			`SELECT target_path, action FROM file_events WHERE action IN ('CREATED', 'DELETED''--');`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := tt.query.Build()
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, sql)
+		})
+	}
+}
+
+// go test -v -cover -run TestQueryBuilder_Errors ./pkg/osquerymanager
+func TestQueryBuilder_Errors(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         *QueryBuilder
+		expectedError string
+	}{
+		{"Table injection", Select("file_events; DROP TABLE x"), "invalid identifier"},
+		{"Column injection", Select("file_events").WhereIn("action = 'x' OR 1", "y"), "invalid identifier"},
+		{"Empty column", Select("file_events", ""), "invalid identifier"},
+		{"NUL byte in value", Select("file_events").WhereLikePrefix("target_path", "/tmp/\x00"), "NUL byte"},
+		{"Empty IN", Select("file_events").WhereIn("action"), "no values"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.query.Build()
+			assert.ErrorContains(t, err, tt.expectedError)
+		})
+	}
+}